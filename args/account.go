@@ -1,6 +1,17 @@
 package args
 
-import "errors"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Page sizes of the account history.
+const (
+	DEFAULT_HISTORY_PAGE_SIZE = 20
+	MAX_HISTORY_PAGE_SIZE     = 100
+)
 
 type CreateAccountArgs struct {
 	Header     int    `json:"header"`
@@ -24,6 +35,16 @@ type CheckAccountArgs struct {
 	Wallet  string `json:"wallet"`
 }
 
+// Selects a page of the account's tx with from <= height <= to, newest first. Pages start at 1. Tx not verified
+// yet are only listed if to is math.MaxUint32.
+type AccountHistoryArgs struct {
+	CheckAccountArgs
+	From     uint64 `json:"from"`
+	To       uint64 `json:"to"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pagesize"`
+}
+
 func (args CreateAccountArgs) ValidateInput() error {
 	if args.Fee <= 0 {
 		return errors.New("invalid argument: Fee must be > 0")
@@ -79,3 +100,33 @@ func (args CheckAccountArgs) ValidateInput() error {
 
 	return nil
 }
+
+func (args AccountHistoryArgs) ValidateInput() error {
+	if err := args.CheckAccountArgs.ValidateInput(); err != nil {
+		return err
+	}
+
+	if len(args.Address) > 0 {
+		if _, err := hex.DecodeString(args.Address); err != nil {
+			return errors.New("invalid argument: Address must be hex encoded")
+		}
+	}
+
+	if args.To > math.MaxUint32 {
+		return errors.New(fmt.Sprintf("invalid argument: to must be <= %v", uint32(math.MaxUint32)))
+	}
+
+	if args.From > args.To {
+		return errors.New("invalid argument: from must be <= to")
+	}
+
+	if args.Page < 1 {
+		return errors.New("invalid argument: page must be >= 1")
+	}
+
+	if args.PageSize < 1 || args.PageSize > MAX_HISTORY_PAGE_SIZE {
+		return errors.New(fmt.Sprintf("invalid argument: pagesize must be between 1 and %v", MAX_HISTORY_PAGE_SIZE))
+	}
+
+	return nil
+}
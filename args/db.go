@@ -0,0 +1,18 @@
+package args
+
+import (
+	"errors"
+)
+
+type DbArchiveArgs struct {
+	File  string
+	Merge bool
+}
+
+func (args DbArchiveArgs) ValidateInput() error {
+	if len(args.File) == 0 {
+		return errors.New("argument missing: file")
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package args
+
+import (
+	"errors"
+	"github.com/way365/bazo-client/util"
+	"path/filepath"
+)
+
+type ReplayArgs struct {
+	Capture string
+	Db      string
+}
+
+func (args ReplayArgs) ValidateInput() error {
+	if len(args.Capture) == 0 {
+		return errors.New("argument missing: capture")
+	}
+
+	if len(args.Db) == 0 {
+		return errors.New("argument missing: db")
+	}
+
+	//The replay starts from an empty DB, the synced chain must not be overwritten.
+	if filepath.Clean(args.Db) == filepath.Clean(util.Config.Storage.Path) {
+		return errors.New("invalid argument: db must not be the client's DB")
+	}
+
+	return nil
+}
@@ -32,3 +32,15 @@ func (args NetworkArgs) ValidateInput() error {
 
 	return nil
 }
+
+type ParametersArgs struct {
+	Height int //-1 for the last synced header
+}
+
+func (args ParametersArgs) ValidateInput() error {
+	if args.Height < -1 {
+		return errors.New("invalid argument: height must be >= 0, or -1 for the last synced header")
+	}
+
+	return nil
+}
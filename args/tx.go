@@ -0,0 +1,33 @@
+package args
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+type TxStatusArgs struct {
+	Hash string `json:"hash"`
+}
+
+func (args TxStatusArgs) ValidateInput() error {
+	if len(args.Hash) == 0 {
+		return errors.New("argument missing: hash")
+	}
+
+	if len(args.Hash) != 64 {
+		return errors.New("invalid argument length: hash")
+	}
+
+	if _, err := hex.DecodeString(args.Hash); err != nil {
+		return errors.New("invalid argument: hash must be hex encoded")
+	}
+
+	return nil
+}
+
+func (args TxStatusArgs) ResolveHash() (txHash [32]byte) {
+	decoded, _ := hex.DecodeString(args.Hash)
+	copy(txHash[:], decoded)
+
+	return txHash
+}
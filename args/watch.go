@@ -0,0 +1,33 @@
+package args
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+type WatchArgs struct {
+	Address string `json:"address"`
+}
+
+func (args WatchArgs) ValidateInput() error {
+	if len(args.Address) == 0 {
+		return errors.New("argument missing: address")
+	}
+
+	if len(args.Address) != 128 {
+		return errors.New("invalid argument length: address")
+	}
+
+	if _, err := hex.DecodeString(args.Address); err != nil {
+		return errors.New("invalid argument: address must be hex encoded")
+	}
+
+	return nil
+}
+
+func (args WatchArgs) ResolveAddress() (address [64]byte) {
+	decoded, _ := hex.DecodeString(args.Address)
+	copy(address[:], decoded)
+
+	return address
+}
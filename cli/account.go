@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"context"
 	"github.com/urfave/cli"
 	"github.com/way365/bazo-client/args"
 	"github.com/way365/bazo-client/services"
 	"log"
+	"math"
 )
 
 var (
@@ -26,19 +28,21 @@ var (
 	}
 )
 
-func GetAccountCommand(logger *log.Logger) cli.Command {
+func GetAccountCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "account",
 		Usage: "account management",
 		Subcommands: []cli.Command{
-			getCheckAccountCommand(logger),
-			getCreateAccountCommand(logger),
-			getAddAccountCommand(logger),
+			getCheckAccountCommand(client, logger),
+			getBalanceAccountCommand(ctx, client, logger),
+			getHistoryAccountCommand(ctx, client, logger),
+			getCreateAccountCommand(client, logger),
+			getAddAccountCommand(client, logger),
 		},
 	}
 }
 
-func getCheckAccountCommand(logger *log.Logger) cli.Command {
+func getCheckAccountCommand(client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "check",
 		Usage: "check account state",
@@ -48,7 +52,7 @@ func getCheckAccountCommand(logger *log.Logger) cli.Command {
 				Wallet:  c.String("wallet"),
 			}
 
-			return services.CheckAccount(args, logger)
+			return client.CheckAccount(args, logger)
 		},
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -64,7 +68,84 @@ func getCheckAccountCommand(logger *log.Logger) cli.Command {
 	}
 }
 
-func getCreateAccountCommand(logger *log.Logger) cli.Command {
+func getBalanceAccountCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
+	return cli.Command{
+		Name:  "balance",
+		Usage: "compute the account's balance from verified transactions",
+		Action: func(c *cli.Context) error {
+			args := &args.CheckAccountArgs{
+				Address: c.String("address"),
+				Wallet:  c.String("wallet"),
+			}
+
+			return client.CheckAccountBalance(ctx, args, logger)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "the account's 128 byte Address",
+			},
+			cli.StringFlag{
+				Name:  "wallet",
+				Usage: "load the account's 128 byte Address from `FILE`",
+				Value: "wallet.txt",
+			},
+		},
+	}
+}
+
+func getHistoryAccountCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
+	return cli.Command{
+		Name:  "history",
+		Usage: "list the transactions the account sent, received or issued, newest first",
+		Action: func(c *cli.Context) error {
+			args := &args.AccountHistoryArgs{
+				CheckAccountArgs: args.CheckAccountArgs{
+					Address: c.String("address"),
+					Wallet:  c.String("wallet"),
+				},
+				From:     c.Uint64("from"),
+				To:       c.Uint64("to"),
+				Page:     c.Int("page"),
+				PageSize: c.Int("pagesize"),
+			}
+
+			return client.ShowAccountHistory(ctx, args, logger)
+		},
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "the account's 128 byte Address",
+			},
+			cli.StringFlag{
+				Name:  "wallet",
+				Usage: "load the account's 128 byte Address from `FILE`",
+				Value: "wallet.txt",
+			},
+			cli.Uint64Flag{
+				Name:  "from",
+				Usage: "list transactions from this height on",
+			},
+			cli.Uint64Flag{
+				Name:  "to",
+				Usage: "list transactions up to this height, the default includes transactions not verified yet",
+				Value: math.MaxUint32,
+			},
+			cli.IntFlag{
+				Name:  "page",
+				Usage: "the page to list, starting at 1",
+				Value: 1,
+			},
+			cli.IntFlag{
+				Name:  "pagesize",
+				Usage: "the number of transactions per page",
+				Value: args.DEFAULT_HISTORY_PAGE_SIZE,
+			},
+		},
+	}
+}
+
+func getCreateAccountCommand(client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "create",
 		Usage: "create a new account and add it to the network",
@@ -78,7 +159,7 @@ func getCreateAccountCommand(logger *log.Logger) cli.Command {
 				Data:       c.String("data"),
 			}
 
-			_, err := services.PrepareSignSubmitCreateAccTx(args, logger)
+			_, err := client.PrepareSignSubmitCreateAccTx(args, logger)
 
 			return err
 		},
@@ -103,7 +184,7 @@ func getCreateAccountCommand(logger *log.Logger) cli.Command {
 	}
 }
 
-func getAddAccountCommand(logger *log.Logger) cli.Command {
+func getAddAccountCommand(client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "add",
 		Usage: "add an existing account",
@@ -116,7 +197,7 @@ func getAddAccountCommand(logger *log.Logger) cli.Command {
 				Parameters: c.String("chparams"),
 			}
 
-			return services.AddAccount(args, logger)
+			return client.AddAccount(args, logger)
 		},
 		Flags: []cli.Flag{
 			headerFlag,
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"github.com/urfave/cli"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/services"
+	"log"
+)
+
+func GetDbCommand(client *services.Client, logger *log.Logger) cli.Command {
+	fileFlag := cli.StringFlag{
+		Name:  "file",
+		Usage: "the archive's `FILE`",
+	}
+
+	return cli.Command{
+		Name:  "db",
+		Usage: "back up and restore the client's DB",
+		Subcommands: []cli.Command{
+			{
+				Name:  "export",
+				Usage: "write the whole DB to a JSON Lines archive",
+				Action: func(c *cli.Context) error {
+					args := &args.DbArchiveArgs{
+						File: c.String("file"),
+					}
+
+					return client.ExportDb(args, logger)
+				},
+				Flags: []cli.Flag{
+					fileFlag,
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "restore the DB from an archive written by export",
+				Action: func(c *cli.Context) error {
+					args := &args.DbArchiveArgs{
+						File:  c.String("file"),
+						Merge: c.Bool("merge"),
+					}
+
+					return client.ImportDb(args, logger)
+				},
+				Flags: []cli.Flag{
+					fileFlag,
+					cli.BoolFlag{
+						Name:  "merge",
+						Usage: "merge the archive into the DB instead of replacing it",
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"github.com/urfave/cli"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/services"
+	"log"
+)
+
+func GetDebugCommand(ctx context.Context, logger *log.Logger) cli.Command {
+	return cli.Command{
+		Name:  "debug",
+		Usage: "debug the client",
+		Subcommands: []cli.Command{
+			{
+				Name:  "replay",
+				Usage: "replay a capture recorded with --record against a fresh DB",
+				Action: func(c *cli.Context) error {
+					args := &args.ReplayArgs{
+						Capture: c.String("capture"),
+						Db:      c.String("db"),
+					}
+
+					return services.ReplayCapture(ctx, args, logger)
+				},
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "capture",
+						Usage: "load the recorded messages from `FILE`",
+					},
+					cli.StringFlag{
+						Name:  "db",
+						Usage: "replay into the fresh DB `FILE`, it is overwritten",
+						Value: "replay.db",
+					},
+				},
+			},
+		},
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"log"
 )
 
-func GetFundsCommand(logger *log.Logger) cli.Command {
+func GetFundsCommand(client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "funds",
 		Usage: "send funds from one account to another",
@@ -29,7 +29,7 @@ func GetFundsCommand(logger *log.Logger) cli.Command {
 				return err
 			}
 
-			_, err = services.PrepareSignSubmitFundsTx(args, logger)
+			_, err = client.PrepareSignSubmitFundsTx(args, logger)
 
 			return err
 		},
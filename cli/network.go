@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"github.com/urfave/cli"
 	"github.com/way365/bazo-client/args"
@@ -8,7 +9,7 @@ import (
 	"log"
 )
 
-func GetNetworkCommand(logger *log.Logger) cli.Command {
+func GetNetworkCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
 	options := []args.ConfigOption{
 		{Id: 1, Name: "setBlockSize", Usage: "set the size of blocks (in bytes)"},
 		{Id: 2, Name: "setDifficultyInterval", Usage: "set the difficulty interval (in number of blocks)"},
@@ -20,6 +21,9 @@ func GetNetworkCommand(logger *log.Logger) cli.Command {
 	command := cli.Command{
 		Name:  "network",
 		Usage: "configure the network",
+		Subcommands: []cli.Command{
+			getParametersCommand(ctx, client, logger),
+		},
 		Action: func(c *cli.Context) error {
 			optionsSetByUser := 0
 			for _, option := range options {
@@ -38,7 +42,7 @@ func GetNetworkCommand(logger *log.Logger) cli.Command {
 					TxCount:    c.Int("TxCount"),
 				}
 
-				err := services.ConfigureNetwork(args, logger)
+				err := client.ConfigureNetwork(args, logger)
 				if err != nil {
 					return err
 				}
@@ -79,3 +83,27 @@ func GetNetworkCommand(logger *log.Logger) cli.Command {
 
 	return command
 }
+
+func getParametersCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
+	return cli.Command{
+		Name:  "params",
+		Usage: "show the network parameters as of a block height",
+		Action: func(c *cli.Context) error {
+			args := &args.ParametersArgs{
+				Height: -1,
+			}
+
+			if c.IsSet("height") {
+				args.Height = c.Int("height")
+			}
+
+			return client.ShowParameters(ctx, args, logger)
+		},
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "height",
+				Usage: "show the parameters as of height `N` instead of the last synced header",
+			},
+		},
+	}
+}
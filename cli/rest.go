@@ -1,19 +1,22 @@
 package cli
 
 import (
+	"context"
 	"github.com/urfave/cli"
 	"github.com/way365/bazo-client/http"
 	"github.com/way365/bazo-client/services"
 )
 
-func GetRestCommand() cli.Command {
+func GetRestCommand(ctx context.Context, client *services.Client) cli.Command {
 	return cli.Command{
 		Name:  "rest",
 		Usage: "start the rest service",
 		Action: func(c *cli.Context) error {
-			services.Sync()
-			http.Init()
-			return nil
+			if err := client.Sync(ctx); err != nil {
+				return err
+			}
+
+			return http.Init(ctx, client)
 		},
 	}
 }
@@ -7,7 +7,7 @@ import (
 	"log"
 )
 
-func GetStakingCommand(logger *log.Logger) cli.Command {
+func GetStakingCommand(client *services.Client, logger *log.Logger) cli.Command {
 	headerFlag := cli.IntFlag{
 		Name:  "header",
 		Usage: "Header flag",
@@ -36,7 +36,7 @@ func GetStakingCommand(logger *log.Logger) cli.Command {
 				Action: func(c *cli.Context) error {
 					args := args.ParseStakingArgs(c)
 					args.StakingValue = true
-					return services.ToggleStaking(args, logger)
+					return client.ToggleStaking(args, logger)
 				},
 				Flags: []cli.Flag{
 					headerFlag,
@@ -55,7 +55,7 @@ func GetStakingCommand(logger *log.Logger) cli.Command {
 				Action: func(c *cli.Context) error {
 					args := args.ParseStakingArgs(c)
 					args.StakingValue = false
-					return services.ToggleStaking(args, logger)
+					return client.ToggleStaking(args, logger)
 				},
 				Flags: []cli.Flag{
 					headerFlag,
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"github.com/urfave/cli"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/services"
+	"log"
+)
+
+func GetTxCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
+	return cli.Command{
+		Name:  "tx",
+		Usage: "inspect stored transactions",
+		Subcommands: []cli.Command{
+			{
+				Name:      "status",
+				Usage:     "show where a transaction stands, from its preparation to its verified inclusion in a block",
+				ArgsUsage: "<hash>",
+				Action: func(c *cli.Context) error {
+					args := &args.TxStatusArgs{
+						Hash: c.Args().First(),
+					}
+
+					return client.ShowTxStatus(ctx, args, logger)
+				},
+			},
+		},
+	}
+}
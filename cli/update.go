@@ -7,7 +7,7 @@ import (
 	"log"
 )
 
-func GetUpdateTxCommand(logger *log.Logger) cli.Command {
+func GetUpdateTxCommand(client *services.Client, logger *log.Logger) cli.Command {
 	return cli.Command{
 		Name:  "update",
 		Usage: "update the data field of a specific transaction",
@@ -26,7 +26,7 @@ func GetUpdateTxCommand(logger *log.Logger) cli.Command {
 				return err
 			}
 
-			_, err = services.PrepareSignSubmitUpdateTx(args, logger)
+			_, err = client.PrepareSignSubmitUpdateTx(args, logger)
 			return err
 		},
 		Flags: []cli.Flag{
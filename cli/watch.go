@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"github.com/urfave/cli"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/services"
+	"log"
+)
+
+func GetWatchCommand(ctx context.Context, client *services.Client, logger *log.Logger) cli.Command {
+	addressFlag := cli.StringFlag{
+		Name:  "address",
+		Usage: "the account's 128 byte address",
+	}
+
+	return cli.Command{
+		Name:  "watch",
+		Usage: "track the activity of accounts",
+		Subcommands: []cli.Command{
+			{
+				Name:  "add",
+				Usage: "add an account to the watch list",
+				Action: func(c *cli.Context) error {
+					args := &args.WatchArgs{
+						Address: c.String("address"),
+					}
+
+					return client.AddWatchedAddress(args, logger)
+				},
+				Flags: []cli.Flag{
+					addressFlag,
+				},
+			},
+			{
+				Name:  "remove",
+				Usage: "remove an account and its recorded activity from the watch list",
+				Action: func(c *cli.Context) error {
+					args := &args.WatchArgs{
+						Address: c.String("address"),
+					}
+
+					return client.RemoveWatchedAddress(args, logger)
+				},
+				Flags: []cli.Flag{
+					addressFlag,
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "list the watched accounts",
+				Action: func(c *cli.Context) error {
+					return client.ListWatchedAddresses(logger)
+				},
+			},
+			{
+				Name:  "activity",
+				Usage: "show the verified activity of a watched account",
+				Action: func(c *cli.Context) error {
+					args := &args.WatchArgs{
+						Address: c.String("address"),
+					}
+
+					return client.ShowWatchActivity(ctx, args, logger)
+				},
+				Flags: []cli.Flag{
+					addressFlag,
+				},
+			},
+		},
+	}
+}
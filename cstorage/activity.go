@@ -0,0 +1,47 @@
+package cstorage
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// A verified transaction of a watched address.
+type Activity struct {
+	Height    uint32
+	BlockHash [32]byte
+	TxHash    [32]byte
+	TxType    string //"funds", "acc" or "stake"
+	From      [32]byte
+	To        [32]byte
+	Amount    uint64
+	Fee       uint64
+	IsStaking bool //Only set for "stake"
+}
+
+func (activity *Activity) Encode() []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(activity)
+
+	return buffer.Bytes()
+}
+
+func (*Activity) Decode(encoded []byte) (activity *Activity) {
+	if encoded == nil {
+		return nil
+	}
+
+	activity = new(Activity)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(activity); err != nil {
+		return nil
+	}
+
+	return activity
+}
+
+// Activities are keyed by address, height and tx hash, so that a cursor iterates an address' activity in chain order.
+func activityKey(address [64]byte, height uint32, txHash [32]byte) []byte {
+	key := append([]byte{}, address[:]...)
+	key = append(key, heightKey(height)...)
+
+	return append(key, txHash[:]...)
+}
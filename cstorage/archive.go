@@ -0,0 +1,276 @@
+package cstorage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/way365/bazo-miner/protocol"
+	"hash"
+	"io"
+	"time"
+)
+
+// Archives are JSON Lines: a header, one record per key of every bucket and a trailer with the number of records
+// and the SHA-256 of the record lines.
+const (
+	ARCHIVE_FORMAT  = "bazo-client-db"
+	ARCHIVE_VERSION = 1
+
+	//Upper bound for a single line, headers with large bloom filters have to fit.
+	MAX_ARCHIVE_LINE_SIZE = 16 * 1024 * 1024
+)
+
+// Buckets derived from the synced chain. Merging keeps the DB's chain if it has one, the archive's chain is only
+// merged as branch headers. Merged tx without an index entry are then indexed as pending.
+var chainBuckets = map[string]bool{
+	LAST_BLOCK_HEADER_BUCKET: true,
+	BLOCK_HEIGHT_BUCKET:      true,
+	REORG_BUCKET:             true,
+	CHECKPOINT_BUCKET:        true,
+	PARAMETERS_BUCKET:        true,
+	SYNC_BUCKET:              true,
+	ACTIVITY_BUCKET:          true,
+	SENDER_INDEX_BUCKET:      true,
+	RECIPIENT_INDEX_BUCKET:   true,
+	ISSUER_INDEX_BUCKET:      true,
+	TX_INDEX_BUCKET:          true,
+}
+
+type archiveHeader struct {
+	Format        string    `json:"format"`
+	Version       uint32    `json:"version"`
+	SchemaVersion uint32    `json:"schemaVersion"`
+	Created       time.Time `json:"created"`
+}
+
+type archiveRecord struct {
+	Bucket string `json:"bucket"`
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+}
+
+type archiveTrailer struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// Writes every bucket but the meta bucket to w. The schema version is part of the archive header.
+func (store *BoltStore) Export(w io.Writer) (records int, err error) {
+	buffered := bufio.NewWriter(w)
+	checksum := sha256.New()
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		header := archiveHeader{ARCHIVE_FORMAT, ARCHIVE_VERSION, SCHEMA_VERSION, time.Now()}
+		if err := writeArchiveLine(buffered, nil, header); err != nil {
+			return err
+		}
+
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) == META_BUCKET {
+				return nil
+			}
+
+			return b.ForEach(func(k, v []byte) error {
+				records++
+				return writeArchiveLine(buffered, checksum, archiveRecord{string(name), k, v})
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeArchiveLine(buffered, nil, archiveTrailer{records, hex.EncodeToString(checksum.Sum(nil))})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return records, buffered.Flush()
+}
+
+// The line is added to checksum if it is not nil.
+func writeArchiveLine(w io.Writer, checksum hash.Hash, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+	if checksum != nil {
+		checksum.Write(line)
+	}
+
+	_, err = w.Write(line)
+
+	return err
+}
+
+// Restores an archive written by Export. The whole archive is checked before the DB is changed, and it is
+// restored in a single transaction. Without merge, the DB is replaced by the archive. Archives of an older schema
+// version are migrated after they are restored. With merge, the archive's entries are added to the DB, entries in
+// both keep the DB's value. The synced chain of the DB is kept if it has one.
+func (store *BoltStore) Import(r io.Reader, merge bool) (records int, err error) {
+	header, archived, err := readArchive(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if header.SchemaVersion > SCHEMA_VERSION {
+		return 0, errors.New(fmt.Sprintf("Archive has schema version %v, this client supports up to version %v", header.SchemaVersion, SCHEMA_VERSION))
+	}
+
+	if merge && header.SchemaVersion != SCHEMA_VERSION {
+		return 0, errors.New(fmt.Sprintf("Archive has schema version %v, only archives of version %v can be merged. Import it without merging into an empty DB instead.", header.SchemaVersion, SCHEMA_VERSION))
+	}
+
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		keepChain := false
+		if merge {
+			keepChain = hasChain(tx)
+		} else if err := resetBuckets(tx, header.SchemaVersion); err != nil {
+			return err
+		}
+
+		for _, record := range archived {
+			b := tx.Bucket([]byte(record.Bucket))
+			if b == nil {
+				return errors.New(fmt.Sprintf("Archive contains the unknown bucket %v", record.Bucket))
+			}
+
+			if merge && ((keepChain && chainBuckets[record.Bucket]) || b.Get(record.Key) != nil) {
+				continue
+			}
+
+			if err := b.Put(record.Key, record.Value); err != nil {
+				return err
+			}
+
+			records++
+		}
+
+		if merge {
+			if err := indexUnindexedTx(tx); err != nil {
+				return err
+			}
+		}
+
+		for version := header.SchemaVersion; version < SCHEMA_VERSION; version++ {
+			if err := migrations[version].migrate(tx); err != nil {
+				return errors.New(fmt.Sprintf("Migration to schema version %v (%v) failed: %v", version+1, migrations[version].description, err))
+			}
+		}
+
+		return writeSchemaVersion(tx, SCHEMA_VERSION)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return records, nil
+}
+
+// Reads and checks the whole archive: the header must be known, the records must match the trailer's count and
+// checksum and headers must be stored under their hash.
+func readArchive(r io.Reader) (header archiveHeader, records []archiveRecord, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MAX_ARCHIVE_LINE_SIZE)
+
+	var lines [][]byte
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			lines = append(lines, append([]byte{}, scanner.Bytes()...))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return header, nil, errors.New(fmt.Sprintf("Reading archive failed: %v", err))
+	}
+
+	if len(lines) < 2 {
+		return header, nil, errors.New("Archive is truncated: header or trailer missing")
+	}
+
+	if err := json.Unmarshal(lines[0], &header); err != nil || header.Format != ARCHIVE_FORMAT {
+		return header, nil, errors.New("Not a bazo-client DB archive")
+	}
+
+	if header.Version != ARCHIVE_VERSION {
+		return header, nil, errors.New(fmt.Sprintf("Archive version %v is not supported, expected version %v", header.Version, ARCHIVE_VERSION))
+	}
+
+	var trailer archiveTrailer
+	if err := json.Unmarshal(lines[len(lines)-1], &trailer); err != nil || trailer.SHA256 == "" {
+		return header, nil, errors.New("Archive is truncated: trailer missing")
+	}
+
+	checksum := sha256.New()
+	for i, line := range lines[1 : len(lines)-1] {
+		checksum.Write(line)
+		checksum.Write([]byte{'\n'})
+
+		var record archiveRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return header, nil, errors.New(fmt.Sprintf("Archive record %v is invalid: %v", i+1, err))
+		}
+
+		if len(record.Key) == 0 {
+			return header, nil, errors.New(fmt.Sprintf("Archive record %v has no key", i+1))
+		}
+
+		if record.Bucket == BLOCK_HEADER_BUCKET {
+			var blockHeader *protocol.Block
+			if blockHeader = blockHeader.Decode(record.Value); blockHeader == nil || !bytes.Equal(blockHeader.Hash[:], record.Key) {
+				return header, nil, errors.New(fmt.Sprintf("Archive record %v is not a header stored under its hash", i+1))
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) != trailer.Records {
+		return header, nil, errors.New(fmt.Sprintf("Archive is truncated: %v of %v records", len(records), trailer.Records))
+	}
+
+	if hex.EncodeToString(checksum.Sum(nil)) != trailer.SHA256 {
+		return header, nil, errors.New("Archive is corrupt: checksum mismatch")
+	}
+
+	return header, records, nil
+}
+
+func hasChain(tx *bolt.Tx) bool {
+	k, _ := tx.Bucket([]byte(LAST_BLOCK_HEADER_BUCKET)).Cursor().First()
+
+	return k != nil
+}
+
+// Drops every bucket but the meta bucket and creates the layout of the given schema version.
+func resetBuckets(tx *bolt.Tx, version uint32) error {
+	var names [][]byte
+	tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if string(name) != META_BUCKET {
+			names = append(names, append([]byte{}, name...))
+		}
+
+		return nil
+	})
+
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations[:version] {
+		if err := m.migrate(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
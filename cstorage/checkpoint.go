@@ -0,0 +1,48 @@
+package cstorage
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// A checkpoint holds the light-client state of an account up to and including the header at Height. Computing the
+// state again only processes the blocks after the checkpoint.
+type Checkpoint struct {
+	Balance        uint64
+	TxCnt          uint32
+	Height         uint32   //Last processed height
+	Hash           [32]byte //Hash of the header at Height, used to detect rolled back checkpoints
+	LastTenTx      [][]byte //Encoded FundsTx received last, oldest first
+	IsCreated      bool
+	IssuanceHeight uint32
+	IsStaking      bool
+	Staking        []StakingEvent //Every time the account joined or left the validator pool, oldest first
+}
+
+// A verified StakeTx of an account.
+type StakingEvent struct {
+	Height    uint32
+	BlockHash [32]byte
+	TxHash    [32]byte
+	IsStaking bool //True if the account joined the validator pool, false if it left
+}
+
+func (checkpoint *Checkpoint) Encode() []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(checkpoint)
+
+	return buffer.Bytes()
+}
+
+func (*Checkpoint) Decode(encoded []byte) (checkpoint *Checkpoint) {
+	if encoded == nil {
+		return nil
+	}
+
+	checkpoint = new(Checkpoint)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(checkpoint); err != nil {
+		return nil
+	}
+
+	return checkpoint
+}
@@ -1,12 +1,144 @@
 package cstorage
 
-import "github.com/boltdb/bolt"
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/boltdb/bolt"
+	"github.com/way365/bazo-miner/protocol"
+)
 
-func DeleteBlockHeader(hash [32]byte) {
-	db.Update(func(tx *bolt.Tx) error {
+func (store *BoltStore) DeleteBlockHeader(hash [32]byte) {
+	store.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("blockheaders"))
+
+		//Only drop the index entry if it still points at the deleted header.
+		var header *protocol.Block
+		if header = header.Decode(b.Get(hash[:])); header != nil {
+			hb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+			if bytes.Equal(hb.Get(heightKey(header.Height)), hash[:]) {
+				if err := hb.Delete(heightKey(header.Height)); err != nil {
+					return err
+				}
+			}
+		}
+
 		err := b.Delete(hash[:])
 
 		return err
 	})
 }
+
+func (store *BoltStore) DeleteCheckpoint(addressHash [32]byte) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(CHECKPOINT_BUCKET))
+		err := b.Delete(addressHash[:])
+
+		return err
+	})
+}
+
+// Deletes all checkpoints at or above the given height. Called when a reorg rolls back the headers from height on.
+func (store *BoltStore) DeleteCheckpointsFrom(height uint32) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(CHECKPOINT_BUCKET))
+
+		var rolledBack [][]byte
+		b.ForEach(func(k, v []byte) error {
+			var checkpoint *Checkpoint
+			if checkpoint = checkpoint.Decode(v); checkpoint == nil || checkpoint.Height >= height {
+				rolledBack = append(rolledBack, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+
+		for _, k := range rolledBack {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Deletes the timeline entries at or above the given height, so that these heights are synced again.
+func (store *BoltStore) DeleteParametersFrom(height uint32) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PARAMETERS_BUCKET))
+
+		var rolledBack [][]byte
+		cb := b.Cursor()
+		for k, _ := cb.Seek(heightKey(height)); k != nil; k, _ = cb.Next() {
+			rolledBack = append(rolledBack, append([]byte{}, k...))
+		}
+
+		for _, k := range rolledBack {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		sb := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := sb.Get([]byte(PARAMETERS_SYNC_KEY)); v != nil && binary.BigEndian.Uint32(v) > height {
+			return sb.Put([]byte(PARAMETERS_SYNC_KEY), heightKey(height))
+		}
+
+		return nil
+	})
+}
+
+// Deletes the address from the watch list together with its recorded activity.
+func (store *BoltStore) DeleteWatchedAddress(address [64]byte) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(WATCH_BUCKET)).Delete(address[:]); err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(ACTIVITY_BUCKET))
+
+		var keys [][]byte
+		cb := b.Cursor()
+		for k, _ := cb.Seek(address[:]); k != nil && bytes.HasPrefix(k, address[:]); k, _ = cb.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Deletes all activity at or above the given height, so that these heights are tested against the watch list again.
+func (store *BoltStore) DeleteActivitiesFrom(height uint32) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ACTIVITY_BUCKET))
+
+		var rolledBack [][]byte
+		b.ForEach(func(k, v []byte) error {
+			var activity *Activity
+			if activity = activity.Decode(v); activity == nil || activity.Height >= height {
+				rolledBack = append(rolledBack, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+
+		for _, k := range rolledBack {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		sb := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := sb.Get([]byte(WATCH_SYNC_KEY)); v != nil && binary.BigEndian.Uint32(v) > height {
+			return sb.Put([]byte(WATCH_SYNC_KEY), heightKey(height))
+		}
+
+		return nil
+	})
+}
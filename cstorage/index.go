@@ -0,0 +1,195 @@
+package cstorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/way365/bazo-miner/protocol"
+	"math"
+)
+
+// The height tx are indexed at as long as they are not included in a block of the synced chain. Pending tx sort
+// after every included tx of an address.
+const PENDING_HEIGHT = math.MaxUint32
+
+// A tx found in an address index.
+type IndexedTx struct {
+	TxHash    [32]byte
+	Height    uint32   //PENDING_HEIGHT if the tx is not included in a block of the synced chain
+	BlockHash [32]byte //Only set for included tx
+}
+
+// The address a tx is indexed under, and the index.
+type indexedAddress struct {
+	Index   string
+	Address [32]byte
+}
+
+// Where a tx is indexed. Kept by tx hash, so that the entries can be moved when the tx's height changes.
+type txIndexEntry struct {
+	Height    uint32
+	BlockHash [32]byte
+	Addresses []indexedAddress
+}
+
+func (entry *txIndexEntry) Encode() []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(entry)
+
+	return buffer.Bytes()
+}
+
+func (*txIndexEntry) Decode(encoded []byte) (entry *txIndexEntry) {
+	if encoded == nil {
+		return nil
+	}
+
+	entry = new(txIndexEntry)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(entry); err != nil {
+		return nil
+	}
+
+	return entry
+}
+
+// The sender, recipient and issuer hashes of the tx. ConfigTx are not indexed, they concern no address.
+func indexedAddresses(tx protocol.Transaction) (addresses []indexedAddress) {
+	switch tx := tx.(type) {
+	case *protocol.FundsTx:
+		addresses = append(addresses, indexedAddress{SENDER_INDEX_BUCKET, tx.From}, indexedAddress{RECIPIENT_INDEX_BUCKET, tx.To})
+	case *protocol.AccTx:
+		addresses = append(addresses, indexedAddress{ISSUER_INDEX_BUCKET, tx.Issuer}, indexedAddress{RECIPIENT_INDEX_BUCKET, protocol.SerializeHashContent(tx.PubKey)})
+	case *protocol.StakeTx:
+		addresses = append(addresses, indexedAddress{SENDER_INDEX_BUCKET, tx.Account})
+	case *protocol.UpdateTx:
+		addresses = append(addresses, indexedAddress{ISSUER_INDEX_BUCKET, tx.Issuer})
+	}
+
+	return addresses
+}
+
+// Index entries are keyed by address hash, height and tx hash, so that a cursor iterates an address' tx in chain
+// order.
+func addressIndexKey(addressHash [32]byte, height uint32, txHash [32]byte) []byte {
+	key := append([]byte{}, addressHash[:]...)
+	key = append(key, heightKey(height)...)
+
+	return append(key, txHash[:]...)
+}
+
+// Splits an index key into the height and the tx hash.
+func parseAddressIndexKey(key []byte) (height uint32, txHash [32]byte) {
+	height = binary.BigEndian.Uint32(key[32:36])
+	copy(txHash[:], key[36:])
+
+	return height, txHash
+}
+
+// The buckets holding the stored tx, one per tx type.
+var txBuckets = []string{ACCOUNT_TX_BUCKET, FUND_TX_BUCKET, CONFIG_TX_BUCKET, STAKING_TX_BUCKET, UPDATE_TX_BUCKET}
+
+// The bucket the tx is stored in.
+func txBucket(tx protocol.Transaction) (string, error) {
+	switch tx.(type) {
+	case *protocol.AccTx:
+		return ACCOUNT_TX_BUCKET, nil
+	case *protocol.FundsTx:
+		return FUND_TX_BUCKET, nil
+	case *protocol.ConfigTx:
+		return CONFIG_TX_BUCKET, nil
+	case *protocol.StakeTx:
+		return STAKING_TX_BUCKET, nil
+	case *protocol.UpdateTx:
+		return UPDATE_TX_BUCKET, nil
+	default:
+		return "", errors.New("invalid tx type")
+	}
+}
+
+// Decodes a tx stored in the given bucket.
+func decodeTx(bucket string, encoded []byte) protocol.Transaction {
+	switch bucket {
+	case ACCOUNT_TX_BUCKET:
+		var accTx *protocol.AccTx
+		if accTx = accTx.Decode(encoded); accTx != nil {
+			return accTx
+		}
+	case FUND_TX_BUCKET:
+		var fundsTx *protocol.FundsTx
+		if fundsTx = fundsTx.Decode(encoded); fundsTx != nil {
+			return fundsTx
+		}
+	case CONFIG_TX_BUCKET:
+		var configTx *protocol.ConfigTx
+		if configTx = configTx.Decode(encoded); configTx != nil {
+			return configTx
+		}
+	case STAKING_TX_BUCKET:
+		var stakeTx *protocol.StakeTx
+		if stakeTx = stakeTx.Decode(encoded); stakeTx != nil {
+			return stakeTx
+		}
+	case UPDATE_TX_BUCKET:
+		var updateTx *protocol.UpdateTx
+		if updateTx = updateTx.Decode(encoded); updateTx != nil {
+			return updateTx
+		}
+	}
+
+	return nil
+}
+
+// Indexes the tx under the addresses at the given height. Entries at the tx's previous height are removed.
+func indexTx(boltTx *bolt.Tx, txHash [32]byte, addresses []indexedAddress, height uint32, blockHash [32]byte) error {
+	ib := boltTx.Bucket([]byte(TX_INDEX_BUCKET))
+
+	var entry *txIndexEntry
+	if entry = entry.Decode(ib.Get(txHash[:])); entry != nil {
+		for _, address := range entry.Addresses {
+			if err := boltTx.Bucket([]byte(address.Index)).Delete(addressIndexKey(address.Address, entry.Height, txHash)); err != nil {
+				return err
+			}
+		}
+	}
+
+	entry = &txIndexEntry{height, blockHash, addresses}
+	for _, address := range entry.Addresses {
+		if err := boltTx.Bucket([]byte(address.Index)).Put(addressIndexKey(address.Address, height, txHash), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return ib.Put(txHash[:], entry.Encode())
+}
+
+// Indexes every stored tx without an index entry as pending.
+func indexUnindexedTx(boltTx *bolt.Tx) error {
+	ib := boltTx.Bucket([]byte(TX_INDEX_BUCKET))
+
+	unindexed := make(map[[32]byte]protocol.Transaction)
+	for _, bucket := range txBuckets {
+		boltTx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+			if ib.Get(k) != nil {
+				return nil
+			}
+
+			if tx := decodeTx(bucket, v); tx != nil {
+				var txHash [32]byte
+				copy(txHash[:], k)
+				unindexed[txHash] = tx
+			}
+
+			return nil
+		})
+	}
+
+	for txHash, tx := range unindexed {
+		if err := indexTx(boltTx, txHash, indexedAddresses(tx), PENDING_HEIGHT, [32]byte{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
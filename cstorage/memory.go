@@ -0,0 +1,647 @@
+package cstorage
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/way365/bazo-miner/miner"
+	"github.com/way365/bazo-miner/protocol"
+	"sort"
+	"sync"
+)
+
+// A Store that keeps everything in memory, so that the sync and state logic can run without a DB file. Records
+// are kept encoded like in the bolt DB, hence every read returns a fresh copy.
+type MemoryStore struct {
+	lock sync.RWMutex
+
+	headers      map[[32]byte][]byte
+	heights      map[uint32][32]byte
+	last         []byte
+	reorgs       [][]byte
+	transactions map[[32]byte]storedTx
+	txIndex      map[[32]byte]*txIndexEntry //The address indexes are derived from these entries when read
+	txStatuses   map[[32]byte][]byte
+	checkpoints  map[[32]byte][]byte
+	parameters   map[uint32]miner.Parameters
+	watched      map[[64]byte]uint32 //The height each address is watched from
+	activities   map[string][]byte   //Keyed by activityKey, so that sorted keys are in chain order
+
+	parametersSyncHeight uint32
+	watchSyncHeight      uint32
+	txStatusSyncHeight   uint32
+}
+
+type storedTx struct {
+	bucket  string
+	encoded []byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		headers:      make(map[[32]byte][]byte),
+		heights:      make(map[uint32][32]byte),
+		transactions: make(map[[32]byte]storedTx),
+		txIndex:      make(map[[32]byte]*txIndexEntry),
+		txStatuses:   make(map[[32]byte][]byte),
+		checkpoints:  make(map[[32]byte][]byte),
+		parameters:   make(map[uint32]miner.Parameters),
+		watched:      make(map[[64]byte]uint32),
+		activities:   make(map[string][]byte),
+	}
+}
+
+func (store *MemoryStore) ReadBlockHeader(hash [32]byte) (header *protocol.Block) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return header.Decode(store.headers[hash])
+}
+
+func (store *MemoryStore) ReadBlockHashByHeight(height uint32) [32]byte {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.heights[height]
+}
+
+func (store *MemoryStore) ReadBlockHeaderByHeight(height uint32) (header *protocol.Block) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	hash, ok := store.heights[height]
+	if !ok {
+		return nil
+	}
+
+	return header.Decode(store.headers[hash])
+}
+
+func (store *MemoryStore) ReadBlockHeadersByRange(from uint32, to uint32) (headers []*protocol.Block) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for _, height := range store.sortedHeights() {
+		if height < from || height > to {
+			continue
+		}
+
+		var header *protocol.Block
+		if header = header.Decode(store.headers[store.heights[height]]); header != nil {
+			headers = append(headers, header)
+		}
+	}
+
+	return headers
+}
+
+func (store *MemoryStore) ReadLastBlockHeader() (header *protocol.Block) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return header.Decode(store.last)
+}
+
+func (store *MemoryStore) ReadReorgs() (reorgs []*Reorg) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for _, encoded := range store.reorgs {
+		var reorg *Reorg
+		if reorg = reorg.Decode(encoded); reorg != nil {
+			reorgs = append(reorgs, reorg)
+		}
+	}
+
+	return reorgs
+}
+
+func (store *MemoryStore) WriteBlockHeader(header *protocol.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.headers[header.Hash] = header.EncodeHeader()
+	store.heights[header.Height] = header.Hash
+
+	return nil
+}
+
+func (store *MemoryStore) WriteBranchBlockHeader(header *protocol.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.headers[header.Hash] = header.EncodeHeader()
+
+	return nil
+}
+
+// Same as BoltStore.WriteChainTip. The changes to the height index are collected first and only applied if the
+// walk to the common ancestor succeeds, hence the switch is atomic as well.
+func (store *MemoryStore) WriteChainTip(tip *protocol.Block) (reorg *Reorg, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	var oldTip [32]byte
+	var last *protocol.Block
+	if last = last.Decode(store.last); last != nil {
+		oldTip = last.Hash
+	}
+
+	var rolledBack [][32]byte
+
+	//Index entries above the new tip belong to the old branch. Collect them starting with the old tip.
+	var above []uint32
+	heights := store.sortedHeights()
+	for i := len(heights) - 1; i >= 0 && heights[i] > tip.Height; i-- {
+		rolledBack = append(rolledBack, store.heights[heights[i]])
+		above = append(above, heights[i])
+	}
+
+	//Walk back from the new tip until the index points at the common ancestor.
+	var forkHeight uint32
+	index := make(map[uint32][32]byte)
+	for header := tip; header != nil; {
+		indexed, ok := store.heights[header.Height]
+		if ok && indexed == header.Hash {
+			forkHeight = header.Height + 1
+			break
+		}
+
+		if ok {
+			rolledBack = append(rolledBack, indexed)
+		}
+
+		index[header.Height] = header.Hash
+
+		if header.PrevHash == [32]byte{} {
+			break
+		}
+
+		encodedHeader := store.headers[header.PrevHash]
+		if encodedHeader == nil {
+			return nil, fmt.Errorf("header %x with height %v is not stored", header.PrevHash[:8], header.Height-1)
+		}
+
+		header = header.Decode(encodedHeader)
+	}
+
+	for _, height := range above {
+		delete(store.heights, height)
+	}
+
+	for height, hash := range index {
+		store.heights[height] = hash
+	}
+
+	store.last = tip.EncodeHeader()
+
+	if len(rolledBack) == 0 {
+		return nil, nil
+	}
+
+	reorg = &Reorg{
+		OldTip:     oldTip,
+		NewTip:     tip.Hash,
+		Height:     forkHeight,
+		Depth:      uint32(len(rolledBack)),
+		RolledBack: rolledBack,
+	}
+
+	store.reorgs = append(store.reorgs, reorg.Encode())
+
+	return reorg, nil
+}
+
+func (store *MemoryStore) WriteBlockHeightIndex(last *protocol.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	index := make(map[uint32][32]byte)
+	for header := last; header != nil; {
+		if indexed, ok := store.heights[header.Height]; ok && indexed == header.Hash {
+			break
+		}
+
+		index[header.Height] = header.Hash
+
+		if header.PrevHash == [32]byte{} {
+			break
+		}
+
+		encodedHeader := store.headers[header.PrevHash]
+		if encodedHeader == nil {
+			return fmt.Errorf("header %x with height %v is not stored", header.PrevHash[:8], header.Height-1)
+		}
+
+		header = header.Decode(encodedHeader)
+	}
+
+	for height, hash := range index {
+		store.heights[height] = hash
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) WriteLastBlockHeader(header *protocol.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for height := range store.heights {
+		if height > header.Height {
+			delete(store.heights, height)
+		}
+	}
+
+	store.last = header.EncodeHeader()
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteBlockHeader(hash [32]byte) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	var header *protocol.Block
+	if header = header.Decode(store.headers[hash]); header != nil && store.heights[header.Height] == hash {
+		delete(store.heights, header.Height)
+	}
+
+	delete(store.headers, hash)
+}
+
+// Heights of the index in ascending order.
+func (store *MemoryStore) sortedHeights() (heights []uint32) {
+	for height := range store.heights {
+		heights = append(heights, height)
+	}
+
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	return heights
+}
+
+func (store *MemoryStore) ReadTransaction(txHash [32]byte) protocol.Transaction {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	stored, ok := store.transactions[txHash]
+	if !ok {
+		return nil
+	}
+
+	return decodeTx(stored.bucket, stored.encoded)
+}
+
+func (store *MemoryStore) WriteTransaction(txHash [32]byte, tx protocol.Transaction) error {
+	bucket, err := txBucket(tx)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.transactions[txHash] = storedTx{bucket, tx.Encode()}
+
+	if _, ok := store.txIndex[txHash]; !ok {
+		store.txIndex[txHash] = &txIndexEntry{PENDING_HEIGHT, [32]byte{}, indexedAddresses(tx)}
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) WriteIncludedTransaction(txHash [32]byte, tx protocol.Transaction, height uint32, blockHash [32]byte) error {
+	bucket, err := txBucket(tx)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.transactions[txHash] = storedTx{bucket, tx.Encode()}
+	store.txIndex[txHash] = &txIndexEntry{height, blockHash, indexedAddresses(tx)}
+
+	return nil
+}
+
+func (store *MemoryStore) ReadAddressIndex(index string, addressHash [32]byte, from uint32, to uint32) (txs []*IndexedTx) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for txHash, entry := range store.txIndex {
+		if entry.Height < from || entry.Height > to {
+			continue
+		}
+
+		for _, address := range entry.Addresses {
+			if address.Index == index && address.Address == addressHash {
+				txs = append(txs, &IndexedTx{txHash, entry.Height, entry.BlockHash})
+				break
+			}
+		}
+	}
+
+	//Same order as the keys of the bolt index.
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Height != txs[j].Height {
+			return txs[i].Height < txs[j].Height
+		}
+
+		return bytes.Compare(txs[i].TxHash[:], txs[j].TxHash[:]) < 0
+	})
+
+	return txs
+}
+
+func (store *MemoryStore) RollBackTxIndexFrom(height uint32) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for txHash, entry := range store.txIndex {
+		if entry.Height >= height && entry.Height != PENDING_HEIGHT {
+			store.txIndex[txHash] = &txIndexEntry{PENDING_HEIGHT, [32]byte{}, entry.Addresses}
+		}
+	}
+}
+
+func (store *MemoryStore) ReadTxStatus(txHash [32]byte) (status *TxStatus) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return status.Decode(store.txStatuses[txHash])
+}
+
+func (store *MemoryStore) ReadTxStatuses() (statuses []*TxStatus) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for _, encoded := range store.txStatuses {
+		var status *TxStatus
+		if status = status.Decode(encoded); status != nil {
+			statuses = append(statuses, status)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return bytes.Compare(statuses[i].TxHash[:], statuses[j].TxHash[:]) < 0
+	})
+
+	return statuses
+}
+
+func (store *MemoryStore) ReadTxStatusSyncHeight() uint32 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.txStatusSyncHeight
+}
+
+func (store *MemoryStore) WriteTxStatus(status *TxStatus) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.txStatuses[status.TxHash] = status.Encode()
+
+	return nil
+}
+
+func (store *MemoryStore) WriteTxStatusSyncHeight(height uint32) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.txStatusSyncHeight = height
+
+	return nil
+}
+
+func (store *MemoryStore) RollBackTxStatusesFrom(height uint32) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for txHash, encoded := range store.txStatuses {
+		var status *TxStatus
+		if status = status.Decode(encoded); status == nil || (status.State != TX_INCLUDED && status.State != TX_VERIFIED) || status.Height < height {
+			continue
+		}
+
+		status.State = TX_SUBMITTED
+		status.BlockHash = [32]byte{}
+		status.Height = 0
+		status.Error = ""
+		store.txStatuses[txHash] = status.Encode()
+	}
+
+	if store.txStatusSyncHeight > height {
+		store.txStatusSyncHeight = height
+	}
+}
+
+func (store *MemoryStore) ReadCheckpoint(addressHash [32]byte) (checkpoint *Checkpoint) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return checkpoint.Decode(store.checkpoints[addressHash])
+}
+
+func (store *MemoryStore) WriteCheckpoint(addressHash [32]byte, checkpoint *Checkpoint) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.checkpoints[addressHash] = checkpoint.Encode()
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteCheckpoint(addressHash [32]byte) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.checkpoints, addressHash)
+}
+
+func (store *MemoryStore) DeleteCheckpointsFrom(height uint32) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for addressHash, encoded := range store.checkpoints {
+		var checkpoint *Checkpoint
+		if checkpoint = checkpoint.Decode(encoded); checkpoint == nil || checkpoint.Height >= height {
+			delete(store.checkpoints, addressHash)
+		}
+	}
+}
+
+func (store *MemoryStore) ReadParameters(height uint32) miner.Parameters {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	//The entry of the highest block with ConfigTx at or below height.
+	found := false
+	var entryHeight uint32
+	for h := range store.parameters {
+		if h <= height && (!found || h > entryHeight) {
+			found, entryHeight = true, h
+		}
+	}
+
+	if !found {
+		return miner.NewDefaultParameters()
+	}
+
+	return store.parameters[entryHeight]
+}
+
+func (store *MemoryStore) ReadParametersSyncHeight() uint32 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.parametersSyncHeight
+}
+
+func (store *MemoryStore) WriteParameters(height uint32, parameters miner.Parameters) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.parameters[height] = parameters
+
+	return nil
+}
+
+func (store *MemoryStore) WriteParametersSyncHeight(height uint32) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.parametersSyncHeight = height
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteParametersFrom(height uint32) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for h := range store.parameters {
+		if h >= height {
+			delete(store.parameters, h)
+		}
+	}
+
+	if store.parametersSyncHeight > height {
+		store.parametersSyncHeight = height
+	}
+}
+
+func (store *MemoryStore) ReadWatchedAddresses() (addresses [][64]byte) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	for address := range store.watched {
+		addresses = append(addresses, address)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+
+	return addresses
+}
+
+func (store *MemoryStore) ReadWatchStartHeight(address [64]byte) uint32 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.watched[address]
+}
+
+func (store *MemoryStore) ReadActivities(address [64]byte) (activities []*Activity) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	var keys []string
+	for key := range store.activities {
+		if bytes.HasPrefix([]byte(key), address[:]) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var activity *Activity
+		if activity = activity.Decode(store.activities[key]); activity != nil {
+			activities = append(activities, activity)
+		}
+	}
+
+	return activities
+}
+
+func (store *MemoryStore) ReadWatchSyncHeight() uint32 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.watchSyncHeight
+}
+
+func (store *MemoryStore) WriteWatchedAddress(address [64]byte, height uint32) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if _, watched := store.watched[address]; !watched {
+		store.watched[address] = height
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) WriteActivity(address [64]byte, activity *Activity) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.activities[string(activityKey(address, activity.Height, activity.TxHash))] = activity.Encode()
+
+	return nil
+}
+
+func (store *MemoryStore) WriteWatchSyncHeight(height uint32) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.watchSyncHeight = height
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteWatchedAddress(address [64]byte) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.watched, address)
+
+	for key := range store.activities {
+		if bytes.HasPrefix([]byte(key), address[:]) {
+			delete(store.activities, key)
+		}
+	}
+}
+
+func (store *MemoryStore) DeleteActivitiesFrom(height uint32) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for key, encoded := range store.activities {
+		var activity *Activity
+		if activity = activity.Decode(encoded); activity == nil || activity.Height >= height {
+			delete(store.activities, key)
+		}
+	}
+
+	if store.watchSyncHeight > height {
+		store.watchSyncHeight = height
+	}
+}
+
+func (store *MemoryStore) Close() error {
+	return nil
+}
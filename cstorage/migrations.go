@@ -0,0 +1,196 @@
+package cstorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"os"
+)
+
+// A change of the DB layout. Migrations run in order, each in its own transaction together with the update of
+// the schema version, so that a failed migration leaves the DB at the previous version.
+type migration struct {
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// The DB layout version n is reached by running the first n migrations. Append new migrations, never reorder or
+// change released ones.
+var migrations = []migration{
+	{"create the buckets", createBuckets},
+	{"create the tx status bucket", createTxStatusBucket},
+	{"create the address indexes", createAddressIndexes},
+}
+
+// The schema version written by this client.
+var SCHEMA_VERSION = uint32(len(migrations))
+
+// Brings the DB to SCHEMA_VERSION. The file is backed up before the first migration runs. A DB written by a newer
+// client is refused, its layout is unknown.
+func (store *BoltStore) migrate(dbname string) error {
+	version, err := store.readSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > SCHEMA_VERSION {
+		return errors.New(fmt.Sprintf("%v has schema version %v, this client supports up to version %v", dbname, version, SCHEMA_VERSION))
+	}
+
+	if version == SCHEMA_VERSION {
+		return nil
+	}
+
+	//A new DB has nothing to back up.
+	if !store.isEmpty() {
+		backup := fmt.Sprintf("%v.v%v.bak", dbname, version)
+		if err := store.backupDB(backup); err != nil {
+			return errors.New(fmt.Sprintf("Backing up %v to %v failed: %v", dbname, backup, err))
+		}
+
+		logger.Printf("Migrating %v from schema version %v to %v, backup written to %v\n", dbname, version, SCHEMA_VERSION, backup)
+	}
+
+	for ; version < SCHEMA_VERSION; version++ {
+		m := migrations[version]
+		err := store.db.Update(func(tx *bolt.Tx) error {
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+
+			return writeSchemaVersion(tx, version+1)
+		})
+
+		if err != nil {
+			return errors.New(fmt.Sprintf("Migration to schema version %v (%v) failed: %v", version+1, m.description, err))
+		}
+
+		logger.Printf("Migrated %v to schema version %v: %v\n", dbname, version+1, m.description)
+	}
+
+	return nil
+}
+
+// DBs written before the schema version was recorded have version 0.
+func (store *BoltStore) readSchemaVersion() (version uint32, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(META_BUCKET))
+		if b == nil {
+			return nil
+		}
+
+		encoded := b.Get([]byte(SCHEMA_VERSION_KEY))
+		if encoded == nil {
+			return nil
+		}
+
+		if len(encoded) != 4 {
+			return errors.New(fmt.Sprintf("invalid schema version %x", encoded))
+		}
+
+		version = binary.BigEndian.Uint32(encoded)
+		return nil
+	})
+
+	return version, err
+}
+
+func writeSchemaVersion(tx *bolt.Tx, version uint32) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(META_BUCKET))
+	if err != nil {
+		return err
+	}
+
+	encoded := make([]byte, 4)
+	binary.BigEndian.PutUint32(encoded, version)
+
+	return b.Put([]byte(SCHEMA_VERSION_KEY), encoded)
+}
+
+func (store *BoltStore) isEmpty() bool {
+	empty := true
+	store.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			empty = false
+			return nil
+		})
+	})
+
+	return empty
+}
+
+// Writes a consistent copy of the DB to path.
+func (store *BoltStore) backupDB(path string) error {
+	return store.db.View(func(tx *bolt.Tx) error {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.WriteTo(file); err != nil {
+			file.Close()
+			return err
+		}
+
+		return file.Close()
+	})
+}
+
+// Version 1: the buckets of the client before the schema version was recorded. Older clients created some of
+// them on demand, so existing buckets are kept.
+func createBuckets(tx *bolt.Tx) error {
+	buckets := []string{
+		BLOCK_HEADER_BUCKET,
+		LAST_BLOCK_HEADER_BUCKET,
+		BLOCK_HEIGHT_BUCKET,
+		REORG_BUCKET,
+		CHECKPOINT_BUCKET,
+		PARAMETERS_BUCKET,
+		SYNC_BUCKET,
+		WATCH_BUCKET,
+		ACTIVITY_BUCKET,
+		ACCOUNT_TX_BUCKET,
+		FUND_TX_BUCKET,
+		CONFIG_TX_BUCKET,
+		STAKING_TX_BUCKET,
+		UPDATE_TX_BUCKET,
+	}
+
+	for _, bucket := range buckets {
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+			return errors.New(fmt.Sprintf("Create bucket %v: %v", bucket, err))
+		}
+	}
+
+	return nil
+}
+
+// Version 2: lifecycle records of the stored tx.
+func createTxStatusBucket(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(TX_STATUS_BUCKET))
+
+	return err
+}
+
+// Version 3: the sender, recipient and issuer indexes. The stored tx are indexed as pending. The checkpoints are
+// dropped, so that the next state computation verifies the account's tx again and indexes them at their height.
+func createAddressIndexes(tx *bolt.Tx) error {
+	for _, bucket := range []string{SENDER_INDEX_BUCKET, RECIPIENT_INDEX_BUCKET, ISSUER_INDEX_BUCKET, TX_INDEX_BUCKET} {
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+			return errors.New(fmt.Sprintf("Create bucket %v: %v", bucket, err))
+		}
+	}
+
+	if err := indexUnindexedTx(tx); err != nil {
+		return err
+	}
+
+	if err := tx.DeleteBucket([]byte(CHECKPOINT_BUCKET)); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	_, err := tx.CreateBucket([]byte(CHECKPOINT_BUCKET))
+
+	return err
+}
@@ -1,12 +1,16 @@
 package cstorage
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"github.com/boltdb/bolt"
+	"github.com/way365/bazo-miner/miner"
 	"github.com/way365/bazo-miner/protocol"
 )
 
-func ReadBlockHeader(hash [32]byte) (header *protocol.Block) {
-	db.View(func(tx *bolt.Tx) error {
+func (store *BoltStore) ReadBlockHeader(hash [32]byte) (header *protocol.Block) {
+	store.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("blockheaders"))
 		encodedHeader := b.Get(hash[:])
 		header = header.Decode(encodedHeader)
@@ -21,8 +25,59 @@ func ReadBlockHeader(hash [32]byte) (header *protocol.Block) {
 	return header
 }
 
-func ReadLastBlockHeader() (header *protocol.Block) {
-	db.View(func(tx *bolt.Tx) error {
+func (store *BoltStore) ReadBlockHashByHeight(height uint32) (hash [32]byte) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+		copy(hash[:], b.Get(heightKey(height)))
+
+		return nil
+	})
+
+	return hash
+}
+
+func (store *BoltStore) ReadBlockHeaderByHeight(height uint32) (header *protocol.Block) {
+	store.db.View(func(tx *bolt.Tx) error {
+		hash := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET)).Get(heightKey(height))
+		if hash == nil {
+			return nil
+		}
+
+		encodedHeader := tx.Bucket([]byte(BLOCK_HEADER_BUCKET)).Get(hash)
+		header = header.Decode(encodedHeader)
+
+		return nil
+	})
+
+	return header
+}
+
+// Returns the indexed headers with from <= height <= to in ascending order. Heights without an index entry are skipped.
+func (store *BoltStore) ReadBlockHeadersByRange(from uint32, to uint32) (headers []*protocol.Block) {
+	if from > to {
+		return nil
+	}
+
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HEADER_BUCKET))
+		cb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET)).Cursor()
+
+		max := heightKey(to)
+		for k, hash := cb.Seek(heightKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, hash = cb.Next() {
+			var header *protocol.Block
+			if header = header.Decode(b.Get(hash)); header != nil {
+				headers = append(headers, header)
+			}
+		}
+
+		return nil
+	})
+
+	return headers
+}
+
+func (store *BoltStore) ReadLastBlockHeader() (header *protocol.Block) {
+	store.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("lastblockheader"))
 		cb := b.Cursor()
 		_, encodedHeader := cb.First()
@@ -38,58 +93,221 @@ func ReadLastBlockHeader() (header *protocol.Block) {
 	return header
 }
 
-func ReadTransaction(txHash [32]byte) (transaction protocol.Transaction) {
-	var encodedTx []byte
+// Returns all recorded reorgs, oldest first.
+func (store *BoltStore) ReadReorgs() (reorgs []*Reorg) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(REORG_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			var reorg *Reorg
+			if reorg = reorg.Decode(v); reorg != nil {
+				reorgs = append(reorgs, reorg)
+			}
+
+			return nil
+		})
+	})
+
+	return reorgs
+}
+
+func (store *BoltStore) ReadCheckpoint(addressHash [32]byte) (checkpoint *Checkpoint) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(CHECKPOINT_BUCKET))
+		checkpoint = checkpoint.Decode(b.Get(addressHash[:]))
 
-	var accTx *protocol.AccTx
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(ACCOUNT_TX_BUCKET))
-		encodedTx = b.Get(txHash[:])
 		return nil
 	})
-	if encodedTx != nil {
-		return accTx.Decode(encodedTx)
-	}
 
-	var fundsTx *protocol.FundsTx
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(FUND_TX_BUCKET))
-		encodedTx = b.Get(txHash[:])
+	return checkpoint
+}
+
+// Returns the parameters as of the given height, i.e. the default parameters changed by every ConfigTx up to and
+// including the block at height.
+func (store *BoltStore) ReadParameters(height uint32) (parameters miner.Parameters) {
+	parameters = miner.NewDefaultParameters()
+
+	store.db.View(func(tx *bolt.Tx) error {
+		cb := tx.Bucket([]byte(PARAMETERS_BUCKET)).Cursor()
+
+		k, v := cb.Seek(heightKey(height))
+		if k == nil || !bytes.Equal(k, heightKey(height)) {
+			k, v = cb.Prev()
+		}
+
+		if k == nil {
+			return nil
+		}
+
+		return gob.NewDecoder(bytes.NewReader(v)).Decode(&parameters)
+	})
+
+	return parameters
+}
+
+// Returns the next height whose ConfigTx have not been applied to the parameter timeline yet.
+func (store *BoltStore) ReadParametersSyncHeight() (height uint32) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := b.Get([]byte(PARAMETERS_SYNC_KEY)); v != nil {
+			height = binary.BigEndian.Uint32(v)
+		}
+
 		return nil
 	})
-	if encodedTx != nil {
-		return fundsTx.Decode(encodedTx)
-	}
 
-	var configTx *protocol.ConfigTx
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(CONFIG_TX_BUCKET))
-		encodedTx = b.Get(txHash[:])
+	return height
+}
+
+func (store *BoltStore) ReadWatchedAddresses() (addresses [][64]byte) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WATCH_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			var address [64]byte
+			copy(address[:], k)
+			addresses = append(addresses, address)
+
+			return nil
+		})
+	})
+
+	return addresses
+}
+
+// Returns the height the address is watched from, 0 for addresses added before start heights were kept.
+func (store *BoltStore) ReadWatchStartHeight(address [64]byte) (height uint32) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WATCH_BUCKET))
+		if v := b.Get(address[:]); len(v) == 4 {
+			height = binary.BigEndian.Uint32(v)
+		}
+
 		return nil
 	})
-	if encodedTx != nil {
-		return configTx.Decode(encodedTx)
-	}
 
-	var stakingTx *protocol.StakeTx
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(STAKING_TX_BUCKET))
-		encodedTx = b.Get(txHash[:])
+	return height
+}
+
+// Returns the recorded activity of the address in chain order.
+func (store *BoltStore) ReadActivities(address [64]byte) (activities []*Activity) {
+	store.db.View(func(tx *bolt.Tx) error {
+		cb := tx.Bucket([]byte(ACTIVITY_BUCKET)).Cursor()
+		for k, v := cb.Seek(address[:]); k != nil && bytes.HasPrefix(k, address[:]); k, v = cb.Next() {
+			var activity *Activity
+			if activity = activity.Decode(v); activity != nil {
+				activities = append(activities, activity)
+			}
+		}
+
 		return nil
 	})
-	if encodedTx != nil {
-		return stakingTx.Decode(encodedTx)
-	}
 
-	var updateTx *protocol.UpdateTx
-	db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(UPDATE_TX_BUCKET))
-		encodedTx = b.Get(txHash[:])
+	return activities
+}
+
+// Returns the next height whose headers have not been tested against the watch list yet.
+func (store *BoltStore) ReadWatchSyncHeight() (height uint32) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := b.Get([]byte(WATCH_SYNC_KEY)); v != nil {
+			height = binary.BigEndian.Uint32(v)
+		}
+
+		return nil
+	})
+
+	return height
+}
+
+// Looks the tx up in the bucket of each tx type.
+func (store *BoltStore) ReadTransaction(txHash [32]byte) (transaction protocol.Transaction) {
+	store.db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range txBuckets {
+			if encodedTx := tx.Bucket([]byte(bucket)).Get(txHash[:]); encodedTx != nil {
+				transaction = decodeTx(bucket, encodedTx)
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	return transaction
+}
+
+func (store *BoltStore) ReadTxStatus(txHash [32]byte) (status *TxStatus) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TX_STATUS_BUCKET))
+		status = status.Decode(b.Get(txHash[:]))
+
 		return nil
 	})
-	if encodedTx != nil {
-		return updateTx.Decode(encodedTx)
+
+	return status
+}
+
+func (store *BoltStore) ReadTxStatuses() (statuses []*TxStatus) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TX_STATUS_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			var status *TxStatus
+			if status = status.Decode(v); status != nil {
+				statuses = append(statuses, status)
+			}
+
+			return nil
+		})
+	})
+
+	return statuses
+}
+
+// Returns the next height whose headers have not been tested against the submitted tx yet.
+func (store *BoltStore) ReadTxStatusSyncHeight() (height uint32) {
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := b.Get([]byte(TX_STATUS_SYNC_KEY)); v != nil {
+			height = binary.BigEndian.Uint32(v)
+		}
+
+		return nil
+	})
+
+	return height
+}
+
+// Returns the tx indexed under the address hash in the given index with from <= height <= to in chain order.
+// Pending tx have PENDING_HEIGHT and are only returned if to is PENDING_HEIGHT.
+func (store *BoltStore) ReadAddressIndex(index string, addressHash [32]byte, from uint32, to uint32) (txs []*IndexedTx) {
+	if from > to {
+		return nil
 	}
 
-	return nil
+	store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(index))
+		if b == nil {
+			return nil
+		}
+
+		ib := tx.Bucket([]byte(TX_INDEX_BUCKET))
+
+		cb := b.Cursor()
+		for k, _ := cb.Seek(addressIndexKey(addressHash, from, [32]byte{})); k != nil && bytes.HasPrefix(k, addressHash[:]); k, _ = cb.Next() {
+			height, txHash := parseAddressIndexKey(k)
+			if height > to {
+				break
+			}
+
+			//Only the entry at the tx's current height is valid.
+			var entry *txIndexEntry
+			if entry = entry.Decode(ib.Get(txHash[:])); entry == nil || entry.Height != height {
+				continue
+			}
+
+			txs = append(txs, &IndexedTx{txHash, height, entry.BlockHash})
+		}
+
+		return nil
+	})
+
+	return txs
 }
@@ -0,0 +1,44 @@
+package cstorage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// A reorg is recorded whenever the synced chain switches to a competing branch.
+type Reorg struct {
+	OldTip     [32]byte
+	NewTip     [32]byte
+	Height     uint32     //Height of the first header that differs between both branches
+	Depth      uint32     //Number of headers rolled back
+	RolledBack [][32]byte //Hashes of the rolled back headers, starting with the old tip
+}
+
+func (reorg *Reorg) Encode() []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(reorg)
+
+	return buffer.Bytes()
+}
+
+func (*Reorg) Decode(encoded []byte) (reorg *Reorg) {
+	if encoded == nil {
+		return nil
+	}
+
+	reorg = new(Reorg)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(reorg); err != nil {
+		return nil
+	}
+
+	return reorg
+}
+
+func (reorg *Reorg) String() string {
+	return fmt.Sprintf("Reorg from height %v: old tip %x, new tip %x, depth %v",
+		reorg.Height,
+		reorg.OldTip[:8],
+		reorg.NewTip[:8],
+		reorg.Depth)
+}
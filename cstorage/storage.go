@@ -1,7 +1,8 @@
 package cstorage
 
 import (
-	"fmt"
+	"encoding/binary"
+	"errors"
 	"github.com/boltdb/bolt"
 	"github.com/way365/bazo-client/util"
 	"log"
@@ -9,90 +10,73 @@ import (
 )
 
 var (
-	db     *bolt.DB
 	logger *log.Logger
 )
 
 const (
 	ERROR_MSG                = "Initiate storage aborted: "
+	META_BUCKET              = "meta"
 	LAST_BLOCK_HEADER_BUCKET = "lastblockheader"
 	BLOCK_HEADER_BUCKET      = "blockheaders"
-	ACCOUNT_TX_BUCKET        = "account_transactions"
-	FUND_TX_BUCKET           = "fund_transactions"
-	CONFIG_TX_BUCKET         = "config_transactions"
-	STAKING_TX_BUCKET        = "staking_transactions"
-	UPDATE_TX_BUCKET         = "update_transactions"
+	BLOCK_HEIGHT_BUCKET      = "blockheights"
+	REORG_BUCKET             = "reorgs"
+	CHECKPOINT_BUCKET        = "checkpoints"
+	PARAMETERS_BUCKET        = "parameters"
+	SYNC_BUCKET              = "sync"
+	WATCH_BUCKET             = "watchlist"
+	ACTIVITY_BUCKET          = "activities"
+	TX_STATUS_BUCKET         = "txstatus"
+	SENDER_INDEX_BUCKET      = "senderindex"
+	RECIPIENT_INDEX_BUCKET   = "recipientindex"
+	ISSUER_INDEX_BUCKET      = "issuerindex"
+	TX_INDEX_BUCKET          = "txindex"
+
+	//Key in the meta bucket holding the version of the DB layout.
+	SCHEMA_VERSION_KEY = "schemaversion"
+	//Key in the sync bucket holding the next height whose ConfigTx have to be applied to the parameter timeline.
+	PARAMETERS_SYNC_KEY = "parameters"
+	//Key in the sync bucket holding the next height whose headers have to be tested against the submitted tx.
+	TX_STATUS_SYNC_KEY = "txstatus"
+	//Key in the sync bucket holding the next height whose headers have to be tested against the watch list.
+	WATCH_SYNC_KEY    = "watchlist"
+	ACCOUNT_TX_BUCKET = "account_transactions"
+	FUND_TX_BUCKET    = "fund_transactions"
+	CONFIG_TX_BUCKET  = "config_transactions"
+	STAKING_TX_BUCKET = "staking_transactions"
+	UPDATE_TX_BUCKET  = "update_transactions"
 )
 
-// Entry function for the storage package
-func Init(dbname string) {
+// A Store backed by a bolt DB file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// Opens the bolt DB at dbname. Migrates the DB to SCHEMA_VERSION if it was written by an older client.
+func OpenBoltStore(dbname string) (*BoltStore, error) {
 	logger = util.InitLogger()
 
-	var err error
-	db, err = bolt.Open(dbname, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	db, err := bolt.Open(dbname, 0600, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
-		logger.Fatal(ERROR_MSG, err)
+		return nil, errors.New(ERROR_MSG + err.Error())
 	}
 
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucket([]byte(BLOCK_HEADER_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-
-		return nil
-	})
-
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucket([]byte(LAST_BLOCK_HEADER_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-
-		return nil
-	})
-
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(ACCOUNT_TX_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-		return nil
-	})
-
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(FUND_TX_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-		return nil
-	})
-
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(CONFIG_TX_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-		return nil
-	})
+	store := &BoltStore{db}
+	if err := store.migrate(dbname); err != nil {
+		db.Close()
+		return nil, errors.New(ERROR_MSG + err.Error())
+	}
 
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(STAKING_TX_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-		return nil
-	})
+	return store, nil
+}
 
-	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucketIfNotExists([]byte(UPDATE_TX_BUCKET))
-		if err != nil {
-			return fmt.Errorf(ERROR_MSG+"Create bucket: %s", err)
-		}
-		return nil
-	})
+func (store *BoltStore) Close() error {
+	return store.db.Close()
 }
 
-func TearDown() {
-	db.Close()
+// Heights are stored big endian, so that a bolt cursor iterates the index in chain order.
+func heightKey(height uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, height)
+
+	return key
 }
@@ -0,0 +1,81 @@
+package cstorage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-miner/miner"
+	"github.com/way365/bazo-miner/protocol"
+)
+
+// The storage backends, selected by the storage.backend option of the configuration.
+const (
+	BOLT_STORE   = "bolt"
+	MEMORY_STORE = "memory"
+)
+
+// Everything the client keeps locally: the synced chain, the transactions it prepared and the state derived from
+// the chain. Implemented by BoltStore, which keeps it in a bolt DB file, and MemoryStore, which keeps it in memory.
+type Store interface {
+	ReadBlockHeader(hash [32]byte) *protocol.Block
+	ReadBlockHashByHeight(height uint32) [32]byte
+	ReadBlockHeaderByHeight(height uint32) *protocol.Block
+	ReadBlockHeadersByRange(from uint32, to uint32) []*protocol.Block
+	ReadLastBlockHeader() *protocol.Block
+	ReadReorgs() []*Reorg
+	WriteBlockHeader(header *protocol.Block) error
+	WriteBranchBlockHeader(header *protocol.Block) error
+	WriteChainTip(tip *protocol.Block) (*Reorg, error)
+	WriteBlockHeightIndex(last *protocol.Block) error
+	WriteLastBlockHeader(header *protocol.Block) error
+	DeleteBlockHeader(hash [32]byte)
+
+	ReadTransaction(txHash [32]byte) protocol.Transaction
+	WriteTransaction(txHash [32]byte, tx protocol.Transaction) error
+	WriteIncludedTransaction(txHash [32]byte, tx protocol.Transaction, height uint32, blockHash [32]byte) error
+
+	ReadAddressIndex(index string, addressHash [32]byte, from uint32, to uint32) []*IndexedTx
+	RollBackTxIndexFrom(height uint32)
+
+	ReadTxStatus(txHash [32]byte) *TxStatus
+	ReadTxStatuses() []*TxStatus
+	ReadTxStatusSyncHeight() uint32
+	WriteTxStatus(status *TxStatus) error
+	WriteTxStatusSyncHeight(height uint32) error
+	RollBackTxStatusesFrom(height uint32)
+
+	ReadCheckpoint(addressHash [32]byte) *Checkpoint
+	WriteCheckpoint(addressHash [32]byte, checkpoint *Checkpoint) error
+	DeleteCheckpoint(addressHash [32]byte)
+	DeleteCheckpointsFrom(height uint32)
+
+	ReadParameters(height uint32) miner.Parameters
+	ReadParametersSyncHeight() uint32
+	WriteParameters(height uint32, parameters miner.Parameters) error
+	WriteParametersSyncHeight(height uint32) error
+	DeleteParametersFrom(height uint32)
+
+	ReadWatchedAddresses() [][64]byte
+	ReadWatchStartHeight(address [64]byte) uint32
+	ReadActivities(address [64]byte) []*Activity
+	ReadWatchSyncHeight() uint32
+	WriteWatchedAddress(address [64]byte, height uint32) error
+	WriteActivity(address [64]byte, activity *Activity) error
+	WriteWatchSyncHeight(height uint32) error
+	DeleteWatchedAddress(address [64]byte)
+	DeleteActivitiesFrom(height uint32)
+
+	Close() error
+}
+
+// Opens the store of the given backend. The path is the DB file of the bolt backend and ignored by the memory
+// backend.
+func Open(backend string, path string) (Store, error) {
+	switch backend {
+	case BOLT_STORE:
+		return OpenBoltStore(path)
+	case MEMORY_STORE:
+		return NewMemoryStore(), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("%vunknown storage backend %v", ERROR_MSG, backend))
+	}
+}
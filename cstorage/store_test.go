@@ -0,0 +1,454 @@
+package cstorage
+
+import (
+	"github.com/way365/bazo-miner/miner"
+	"github.com/way365/bazo-miner/protocol"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	//OpenBoltStore creates the performance log in the working directory.
+	dir, err := os.MkdirTemp("", "cstorage")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Chdir(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+
+	os.Exit(code)
+}
+
+// Runs the test against every backend, so that both behave the same.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run(MEMORY_STORE, func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+
+	t.Run(BOLT_STORE, func(t *testing.T) {
+		store, err := OpenBoltStore(filepath.Join(t.TempDir(), "client.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer store.Close()
+		test(t, store)
+	})
+}
+
+// Builds a chain of headers on top of parent. The salt tells competing branches apart.
+func chain(parent *protocol.Block, length int, salt byte) (headers []*protocol.Block) {
+	for i := 0; i < length; i++ {
+		header := new(protocol.Block)
+		if parent != nil {
+			header.PrevHash = parent.Hash
+			header.Height = parent.Height + 1
+		}
+
+		header.Hash = [32]byte{salt, byte(header.Height), 1}
+		headers = append(headers, header)
+		parent = header
+	}
+
+	return headers
+}
+
+func hashes(headers []*protocol.Block) (hashes [][32]byte) {
+	for _, header := range headers {
+		hashes = append(hashes, header.Hash)
+	}
+
+	return hashes
+}
+
+func TestHeaders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		headers := chain(nil, 4, 1)
+		for _, header := range headers {
+			if err := store.WriteBlockHeader(header); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := store.WriteLastBlockHeader(headers[3]); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := store.ReadBlockHeader(headers[2].Hash); got == nil || got.Hash != headers[2].Hash {
+			t.Errorf("ReadBlockHeader() = %v, want %x", got, headers[2].Hash[:8])
+		}
+
+		if got := store.ReadBlockHeader([32]byte{9}); got != nil {
+			t.Errorf("ReadBlockHeader() of an unknown hash = %v, want nil", got)
+		}
+
+		if got := store.ReadBlockHashByHeight(1); got != headers[1].Hash {
+			t.Errorf("ReadBlockHashByHeight(1) = %x, want %x", got[:8], headers[1].Hash[:8])
+		}
+
+		if got := store.ReadBlockHeaderByHeight(7); got != nil {
+			t.Errorf("ReadBlockHeaderByHeight(7) = %v, want nil", got)
+		}
+
+		if got := hashes(store.ReadBlockHeadersByRange(1, 2)); !reflect.DeepEqual(got, hashes(headers[1:3])) {
+			t.Errorf("ReadBlockHeadersByRange(1, 2) = %x, want %x", got, hashes(headers[1:3]))
+		}
+
+		if got := store.ReadBlockHeadersByRange(3, 1); got != nil {
+			t.Errorf("ReadBlockHeadersByRange(3, 1) = %v, want nil", got)
+		}
+
+		if got := store.ReadLastBlockHeader(); got == nil || got.Hash != headers[3].Hash {
+			t.Errorf("ReadLastBlockHeader() = %v, want %x", got, headers[3].Hash[:8])
+		}
+
+		//Moving the last header back drops the index above it.
+		if err := store.WriteLastBlockHeader(headers[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := store.ReadBlockHashByHeight(2); got != [32]byte{} {
+			t.Errorf("ReadBlockHashByHeight(2) = %x after rolling back, want no entry", got[:8])
+		}
+
+		store.DeleteBlockHeader(headers[1].Hash)
+		if got := store.ReadBlockHeader(headers[1].Hash); got != nil {
+			t.Errorf("ReadBlockHeader() = %v after deleting it, want nil", got)
+		}
+
+		if got := store.ReadBlockHashByHeight(1); got != [32]byte{} {
+			t.Errorf("ReadBlockHashByHeight(1) = %x after deleting the header, want no entry", got[:8])
+		}
+	})
+}
+
+func TestBlockHeightIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		headers := chain(nil, 4, 1)
+		for _, header := range headers {
+			store.WriteBranchBlockHeader(header)
+		}
+
+		if got := store.ReadBlockHashByHeight(0); got != [32]byte{} {
+			t.Errorf("Branch header indexed at height 0: %x", got[:8])
+		}
+
+		if err := store.WriteBlockHeightIndex(headers[3]); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := hashes(store.ReadBlockHeadersByRange(0, 3)); !reflect.DeepEqual(got, hashes(headers)) {
+			t.Errorf("ReadBlockHeadersByRange(0, 3) = %x, want %x", got, hashes(headers))
+		}
+
+		missing := chain(headers[3], 2, 1)[1]
+		if err := store.WriteBlockHeightIndex(missing); err == nil {
+			t.Error("WriteBlockHeightIndex() with a missing ancestor succeeded")
+		}
+	})
+}
+
+func TestWriteChainTip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		main := chain(nil, 4, 1)
+		branch := chain(main[1], 3, 2)
+		for _, header := range main {
+			store.WriteBranchBlockHeader(header)
+		}
+
+		for _, header := range branch {
+			store.WriteBranchBlockHeader(header)
+		}
+
+		reorg, err := store.WriteChainTip(main[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reorg != nil {
+			t.Errorf("Extending the chain recorded %v", reorg)
+		}
+
+		reorg, err = store.WriteChainTip(branch[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := &Reorg{
+			OldTip:     main[3].Hash,
+			NewTip:     branch[2].Hash,
+			Height:     2,
+			Depth:      2,
+			RolledBack: [][32]byte{main[3].Hash, main[2].Hash},
+		}
+
+		if !reflect.DeepEqual(reorg, want) {
+			t.Errorf("WriteChainTip() = %v, want %v", reorg, want)
+		}
+
+		if got := store.ReadReorgs(); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+			t.Errorf("ReadReorgs() = %v, want [%v]", got, want)
+		}
+
+		wantIndex := hashes(append(append([]*protocol.Block{}, main[:2]...), branch...))
+		if got := hashes(store.ReadBlockHeadersByRange(0, 10)); !reflect.DeepEqual(got, wantIndex) {
+			t.Errorf("Index after the reorg = %x, want %x", got, wantIndex)
+		}
+
+		//Switching back to the shorter branch drops the index above its tip.
+		reorg, err = store.WriteChainTip(main[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reorg == nil || reorg.Depth != 3 || reorg.Height != 2 {
+			t.Errorf("WriteChainTip() = %v, want depth 3 from height 2", reorg)
+		}
+
+		if got := store.ReadBlockHashByHeight(4); got != [32]byte{} {
+			t.Errorf("ReadBlockHashByHeight(4) = %x, want no entry", got[:8])
+		}
+
+		if got := store.ReadLastBlockHeader(); got == nil || got.Hash != main[3].Hash {
+			t.Errorf("ReadLastBlockHeader() = %v, want %x", got, main[3].Hash[:8])
+		}
+
+		//A tip whose ancestors are not stored leaves everything as it was.
+		orphan := chain(branch[2], 2, 3)[1]
+		if _, err := store.WriteChainTip(orphan); err == nil {
+			t.Error("WriteChainTip() with a missing ancestor succeeded")
+		}
+
+		if got := store.ReadLastBlockHeader(); got == nil || got.Hash != main[3].Hash {
+			t.Errorf("ReadLastBlockHeader() = %v after a failed switch, want %x", got, main[3].Hash[:8])
+		}
+
+		if got := len(store.ReadReorgs()); got != 2 {
+			t.Errorf("%v reorgs recorded, want 2", got)
+		}
+	})
+}
+
+func TestTransactions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		from, to := [32]byte{1}, [32]byte{2}
+		pending := &protocol.FundsTx{Amount: 1, From: from, To: to}
+		included := &protocol.FundsTx{Amount: 2, From: to, To: from}
+		stake := &protocol.StakeTx{Fee: 3, Account: from}
+
+		store.WriteTransaction(pending.Hash(), pending)
+		store.WriteIncludedTransaction(included.Hash(), included, 5, [32]byte{5})
+		store.WriteIncludedTransaction(stake.Hash(), stake, 7, [32]byte{7})
+
+		got, ok := store.ReadTransaction(included.Hash()).(*protocol.FundsTx)
+		if !ok || got.Hash() != included.Hash() {
+			t.Errorf("ReadTransaction() = %v, want %v", got, included)
+		}
+
+		if _, ok := store.ReadTransaction(stake.Hash()).(*protocol.StakeTx); !ok {
+			t.Errorf("ReadTransaction() of a StakeTx = %v", store.ReadTransaction(stake.Hash()))
+		}
+
+		if got := store.ReadTransaction([32]byte{9}); got != nil {
+			t.Errorf("ReadTransaction() of an unknown hash = %v, want nil", got)
+		}
+
+		if err := store.WriteTransaction([32]byte{9}, &protocol.AggTx{}); err == nil {
+			t.Error("WriteTransaction() of an unsupported tx type succeeded")
+		}
+
+		want := []*IndexedTx{{stake.Hash(), 7, [32]byte{7}}, {pending.Hash(), PENDING_HEIGHT, [32]byte{}}}
+		if got := store.ReadAddressIndex(SENDER_INDEX_BUCKET, from, 0, PENDING_HEIGHT); !reflect.DeepEqual(got, want) {
+			t.Errorf("Sender index = %v, want %v", got, want)
+		}
+
+		if got := store.ReadAddressIndex(RECIPIENT_INDEX_BUCKET, from, 0, 6); len(got) != 1 || got[0].TxHash != included.Hash() {
+			t.Errorf("Recipient index up to height 6 = %v, want %x", got, included.Hash())
+		}
+
+		//Rolled back tx stay indexed as pending, writing a pending tx again keeps its height.
+		store.RollBackTxIndexFrom(6)
+		store.WriteTransaction(included.Hash(), included)
+
+		want = []*IndexedTx{{included.Hash(), 5, [32]byte{5}}}
+		if got := store.ReadAddressIndex(SENDER_INDEX_BUCKET, to, 0, PENDING_HEIGHT); !reflect.DeepEqual(got, want) {
+			t.Errorf("Sender index after rolling back = %v, want %v", got, want)
+		}
+
+		for _, tx := range store.ReadAddressIndex(SENDER_INDEX_BUCKET, from, 0, PENDING_HEIGHT) {
+			if tx.Height != PENDING_HEIGHT {
+				t.Errorf("Tx %x indexed at height %v after rolling back, want pending", tx.TxHash[:8], tx.Height)
+			}
+		}
+	})
+}
+
+func TestTxStatuses(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		submitted := &TxStatus{TxHash: [32]byte{1}, State: TX_SUBMITTED}
+		included := &TxStatus{TxHash: [32]byte{2}, State: TX_INCLUDED, Height: 4, BlockHash: [32]byte{4}}
+		verified := &TxStatus{TxHash: [32]byte{3}, State: TX_VERIFIED, Height: 2, BlockHash: [32]byte{2}}
+
+		for _, status := range []*TxStatus{verified, included, submitted} {
+			store.WriteTxStatus(status)
+		}
+
+		store.WriteTxStatusSyncHeight(5)
+
+		var got [][32]byte
+		for _, status := range store.ReadTxStatuses() {
+			got = append(got, status.TxHash)
+		}
+
+		if want := [][32]byte{{1}, {2}, {3}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadTxStatuses() = %x, want %x", got, want)
+		}
+
+		store.RollBackTxStatusesFrom(3)
+
+		if status := store.ReadTxStatus(included.TxHash); status.State != TX_SUBMITTED || status.Height != 0 || status.BlockHash != [32]byte{} {
+			t.Errorf("Rolled back status = %+v, want submitted", status)
+		}
+
+		if status := store.ReadTxStatus(verified.TxHash); status.State != TX_VERIFIED {
+			t.Errorf("Status below the reorg = %v, want verified", status.State)
+		}
+
+		if got := store.ReadTxStatusSyncHeight(); got != 3 {
+			t.Errorf("ReadTxStatusSyncHeight() = %v, want 3", got)
+		}
+
+		if got := store.ReadTxStatus([32]byte{9}); got != nil {
+			t.Errorf("ReadTxStatus() of an unknown hash = %v, want nil", got)
+		}
+	})
+}
+
+func TestCheckpoints(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		low, high := [32]byte{1}, [32]byte{2}
+		store.WriteCheckpoint(low, &Checkpoint{Balance: 10, Height: 3})
+		store.WriteCheckpoint(high, &Checkpoint{Balance: 20, Height: 8})
+
+		if got := store.ReadCheckpoint(high); got == nil || got.Balance != 20 {
+			t.Errorf("ReadCheckpoint() = %v, want balance 20", got)
+		}
+
+		store.DeleteCheckpointsFrom(8)
+		if got := store.ReadCheckpoint(high); got != nil {
+			t.Errorf("Checkpoint at the reorg height kept: %v", got)
+		}
+
+		if got := store.ReadCheckpoint(low); got == nil {
+			t.Error("Checkpoint below the reorg height deleted")
+		}
+
+		store.DeleteCheckpoint(low)
+		if got := store.ReadCheckpoint(low); got != nil {
+			t.Errorf("ReadCheckpoint() = %v after deleting it, want nil", got)
+		}
+	})
+}
+
+func TestParameters(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		defaults := miner.NewDefaultParameters()
+		changed := defaults
+		changed.BlockReward = defaults.BlockReward + 5
+		later := changed
+		later.FeeMinimum = defaults.FeeMinimum + 1
+
+		store.WriteParameters(4, changed)
+		store.WriteParameters(9, later)
+		store.WriteParametersSyncHeight(12)
+
+		tests := []struct {
+			height uint32
+			want   miner.Parameters
+		}{
+			{0, defaults},
+			{3, defaults},
+			{4, changed},
+			{8, changed},
+			{9, later},
+			{100, later},
+		}
+
+		for _, test := range tests {
+			if got := store.ReadParameters(test.height); got != test.want {
+				t.Errorf("ReadParameters(%v) = %+v, want %+v", test.height, got, test.want)
+			}
+		}
+
+		store.DeleteParametersFrom(9)
+
+		if got := store.ReadParameters(100); got != changed {
+			t.Errorf("ReadParameters(100) = %+v after rolling back, want %+v", got, changed)
+		}
+
+		if got := store.ReadParametersSyncHeight(); got != 9 {
+			t.Errorf("ReadParametersSyncHeight() = %v, want 9", got)
+		}
+	})
+}
+
+func TestWatchList(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		first, second := [64]byte{1}, [64]byte{2}
+		store.WriteWatchedAddress(second, 4)
+		store.WriteWatchedAddress(first, 0)
+		store.WriteWatchSyncHeight(10)
+
+		if got := store.ReadWatchedAddresses(); !reflect.DeepEqual(got, [][64]byte{first, second}) {
+			t.Errorf("ReadWatchedAddresses() = %x, want both addresses in order", got)
+		}
+
+		//Adding a watched address again keeps the height it is watched from.
+		store.WriteWatchedAddress(second, 9)
+
+		if got := store.ReadWatchStartHeight(second); got != 4 {
+			t.Errorf("ReadWatchStartHeight() = %v, want 4", got)
+		}
+
+		store.WriteActivity(first, &Activity{Height: 7, TxHash: [32]byte{2}, TxType: "funds"})
+		store.WriteActivity(first, &Activity{Height: 3, TxHash: [32]byte{1}, TxType: "stake"})
+		store.WriteActivity(second, &Activity{Height: 5, TxHash: [32]byte{3}, TxType: "acc"})
+
+		var heights []uint32
+		for _, activity := range store.ReadActivities(first) {
+			heights = append(heights, activity.Height)
+		}
+
+		if want := []uint32{3, 7}; !reflect.DeepEqual(heights, want) {
+			t.Errorf("Activity heights = %v, want %v", heights, want)
+		}
+
+		store.DeleteActivitiesFrom(5)
+
+		if got := store.ReadActivities(first); len(got) != 1 || got[0].Height != 3 {
+			t.Errorf("ReadActivities() after rolling back = %v, want the activity at height 3", got)
+		}
+
+		if got := store.ReadActivities(second); got != nil {
+			t.Errorf("Activity at the reorg height kept: %v", got)
+		}
+
+		if got := store.ReadWatchSyncHeight(); got != 5 {
+			t.Errorf("ReadWatchSyncHeight() = %v, want 5", got)
+		}
+
+		store.DeleteWatchedAddress(first)
+
+		if got := store.ReadWatchedAddresses(); !reflect.DeepEqual(got, [][64]byte{second}) {
+			t.Errorf("ReadWatchedAddresses() = %x after deleting one, want the other", got)
+		}
+
+		if got := store.ReadActivities(first); got != nil {
+			t.Errorf("Activity of an unwatched address kept: %v", got)
+		}
+	})
+}
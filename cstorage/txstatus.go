@@ -0,0 +1,69 @@
+package cstorage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+// Where a stored tx stands. The states are ordered, a tx only moves back on a reorg.
+type TxState uint8
+
+const (
+	TX_PREPARED TxState = iota
+	TX_SIGNED
+	TX_SUBMITTED
+	TX_INCLUDED
+	TX_VERIFIED
+	TX_FAILED
+)
+
+var txStateNames = []string{"prepared", "signed", "submitted", "included", "verified", "failed"}
+
+func (state TxState) String() string {
+	if int(state) < len(txStateNames) {
+		return txStateNames[state]
+	}
+
+	return fmt.Sprintf("unknown (%v)", uint8(state))
+}
+
+// The outcome of submitting the tx to a single miner.
+type TxSubmission struct {
+	Peer     string
+	Time     time.Time
+	Accepted bool
+	Response string //"accepted" or why the miner did not accept the tx
+}
+
+// The lifecycle record of a stored tx.
+type TxStatus struct {
+	TxHash      [32]byte
+	State       TxState
+	Updated     time.Time
+	Submissions []TxSubmission //Every submission, in the order they were made
+	BlockHash   [32]byte       //Set from TX_INCLUDED on
+	Height      uint32         //Set from TX_INCLUDED on
+	Error       string         //Why the submission or the Merkle verification failed
+}
+
+func (status *TxStatus) Encode() []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(status)
+
+	return buffer.Bytes()
+}
+
+func (*TxStatus) Decode(encoded []byte) (status *TxStatus) {
+	if encoded == nil {
+		return nil
+	}
+
+	status = new(TxStatus)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(status); err != nil {
+		return nil
+	}
+
+	return status
+}
@@ -1,25 +1,189 @@
 package cstorage
 
 import (
-	"errors"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
 	"github.com/boltdb/bolt"
+	"github.com/way365/bazo-miner/miner"
 	"github.com/way365/bazo-miner/protocol"
 )
 
-func WriteBlockHeader(header *protocol.Block) (err error) {
-	err = db.Update(func(tx *bolt.Tx) error {
+// Saves the header and points the height index at it.
+func (store *BoltStore) WriteBlockHeader(header *protocol.Block) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("blockheaders"))
-		err := b.Put(header.Hash[:], header.EncodeHeader())
+		if err := b.Put(header.Hash[:], header.EncodeHeader()); err != nil {
+			return err
+		}
 
-		return err
+		hb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+		return hb.Put(heightKey(header.Height), header.Hash[:])
+	})
+
+	return err
+}
+
+// Saves a header of a competing branch without touching the height index.
+func (store *BoltStore) WriteBranchBlockHeader(header *protocol.Block) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HEADER_BUCKET))
+		return b.Put(header.Hash[:], header.EncodeHeader())
+	})
+
+	return err
+}
+
+// Makes the given header the last header of the synced chain. All headers between the common ancestor and the new
+// tip must already be stored. The height index is rewritten for the new branch and the last header is replaced in
+// a single bolt transaction, hence the switch is atomic. If headers of the old branch were rolled back, the reorg
+// is recorded and returned, otherwise nil is returned.
+func (store *BoltStore) WriteChainTip(tip *protocol.Block) (reorg *Reorg, err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HEADER_BUCKET))
+		hb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+		lb := tx.Bucket([]byte(LAST_BLOCK_HEADER_BUCKET))
+
+		var oldTip [32]byte
+		k, _ := lb.Cursor().First()
+		copy(oldTip[:], k)
+
+		var rolledBack [][32]byte
+
+		//Index entries above the new tip belong to the old branch. Collect them starting with the old tip.
+		var above [][]byte
+		cb := hb.Cursor()
+		for k, v := cb.Last(); k != nil && bytes.Compare(k, heightKey(tip.Height)) > 0; k, v = cb.Prev() {
+			var hash [32]byte
+			copy(hash[:], v)
+			rolledBack = append(rolledBack, hash)
+			above = append(above, append([]byte{}, k...))
+		}
+
+		for _, k := range above {
+			if err := hb.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		//Walk back from the new tip until the index points at the common ancestor.
+		var forkHeight uint32
+		for header := tip; header != nil; {
+			indexed := hb.Get(heightKey(header.Height))
+			if bytes.Equal(indexed, header.Hash[:]) {
+				forkHeight = header.Height + 1
+				break
+			}
+
+			if indexed != nil {
+				var hash [32]byte
+				copy(hash[:], indexed)
+				rolledBack = append(rolledBack, hash)
+			}
+
+			if err := hb.Put(heightKey(header.Height), header.Hash[:]); err != nil {
+				return err
+			}
+
+			if header.PrevHash == [32]byte{} {
+				break
+			}
+
+			encodedHeader := b.Get(header.PrevHash[:])
+			if encodedHeader == nil {
+				return fmt.Errorf("header %x with height %v is not stored", header.PrevHash[:8], header.Height-1)
+			}
+
+			header = header.Decode(encodedHeader)
+		}
+
+		var keys [][]byte
+		lb.ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			return nil
+		})
+
+		for _, k := range keys {
+			if err := lb.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		if err := lb.Put(tip.Hash[:], tip.EncodeHeader()); err != nil {
+			return err
+		}
+
+		if len(rolledBack) == 0 {
+			return nil
+		}
+
+		reorg = &Reorg{
+			OldTip:     oldTip,
+			NewTip:     tip.Hash,
+			Height:     forkHeight,
+			Depth:      uint32(len(rolledBack)),
+			RolledBack: rolledBack,
+		}
+
+		rb := tx.Bucket([]byte(REORG_BUCKET))
+		seq, err := rb.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		return rb.Put(key, reorg.Encode())
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reorg, nil
+}
+
+// Walks back from the given header and points the height index at each ancestor. The walk stops as soon as the
+// index already points at the ancestor, hence only the missing part of the index is written. This also builds
+// the index for databases written before the index existed.
+func (store *BoltStore) WriteBlockHeightIndex(last *protocol.Block) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HEADER_BUCKET))
+		hb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+
+		for header := last; header != nil; {
+			if indexed := hb.Get(heightKey(header.Height)); bytes.Equal(indexed, header.Hash[:]) {
+				return nil
+			}
+
+			if err := hb.Put(heightKey(header.Height), header.Hash[:]); err != nil {
+				return err
+			}
+
+			if header.PrevHash == [32]byte{} {
+				return nil
+			}
+
+			encodedHeader := b.Get(header.PrevHash[:])
+			if encodedHeader == nil {
+				return fmt.Errorf("header %x with height %v is not stored", header.PrevHash[:8], header.Height-1)
+			}
+
+			header = header.Decode(encodedHeader)
+		}
+
+		return nil
 	})
 
 	return err
 }
 
-// Before saving the last block header, delete all existing entries.
-func WriteLastBlockHeader(header *protocol.Block) (err error) {
-	db.Update(func(tx *bolt.Tx) error {
+// Before saving the last block header, delete all existing entries. Index entries above the last header belong to
+// a rolled back chain and are removed as well.
+func (store *BoltStore) WriteLastBlockHeader(header *protocol.Block) (err error) {
+	store.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("lastblockheader"))
 		b.ForEach(func(k, v []byte) error {
 			b.Delete(k)
@@ -27,10 +191,21 @@ func WriteLastBlockHeader(header *protocol.Block) (err error) {
 			return nil
 		})
 
+		hb := tx.Bucket([]byte(BLOCK_HEIGHT_BUCKET))
+		var rolledBack [][]byte
+		cb := hb.Cursor()
+		for k, _ := cb.Seek(heightKey(header.Height + 1)); k != nil; k, _ = cb.Next() {
+			rolledBack = append(rolledBack, append([]byte{}, k...))
+		}
+
+		for _, k := range rolledBack {
+			hb.Delete(k)
+		}
+
 		return nil
 	})
 
-	err = db.Update(func(tx *bolt.Tx) error {
+	err = store.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("lastblockheader"))
 		err := b.Put(header.Hash[:], header.EncodeHeader())
 
@@ -40,29 +215,190 @@ func WriteLastBlockHeader(header *protocol.Block) (err error) {
 	return err
 }
 
-func WriteTransaction(txHash [32]byte, tx protocol.Transaction) (err error) {
-	var bucket string
-	switch tx.(type) {
-	case *protocol.AccTx:
-		bucket = ACCOUNT_TX_BUCKET
-	case *protocol.FundsTx:
-		bucket = FUND_TX_BUCKET
-	case *protocol.ConfigTx:
-		bucket = CONFIG_TX_BUCKET
-	case *protocol.StakeTx:
-		bucket = STAKING_TX_BUCKET
-	case *protocol.UpdateTx:
-		bucket = UPDATE_TX_BUCKET
-	default:
-		return errors.New("invalid tx type")
+func (store *BoltStore) WriteCheckpoint(addressHash [32]byte, checkpoint *Checkpoint) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(CHECKPOINT_BUCKET))
+		return b.Put(addressHash[:], checkpoint.Encode())
+	})
+
+	return err
+}
+
+// The parameter timeline holds one entry for every block with ConfigTx, keyed by the block's height. An entry holds
+// the parameters after applying the block's ConfigTx.
+func (store *BoltStore) WriteParameters(height uint32, parameters miner.Parameters) (err error) {
+	var buffer bytes.Buffer
+	if err = gob.NewEncoder(&buffer).Encode(parameters); err != nil {
+		return err
 	}
 
-	err = db.Update(func(boltTx *bolt.Tx) error {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PARAMETERS_BUCKET))
+		return b.Put(heightKey(height), buffer.Bytes())
+	})
+
+	return err
+}
+
+func (store *BoltStore) WriteParametersSyncHeight(height uint32) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		return b.Put([]byte(PARAMETERS_SYNC_KEY), heightKey(height))
+	})
+
+	return err
+}
+
+// Watches the address from the given height on. An address that is watched already keeps its start height.
+func (store *BoltStore) WriteWatchedAddress(address [64]byte, height uint32) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WATCH_BUCKET))
+		if b.Get(address[:]) != nil {
+			return nil
+		}
+
+		return b.Put(address[:], heightKey(height))
+	})
+
+	return err
+}
+
+func (store *BoltStore) WriteActivity(address [64]byte, activity *Activity) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ACTIVITY_BUCKET))
+		return b.Put(activityKey(address, activity.Height, activity.TxHash), activity.Encode())
+	})
+
+	return err
+}
+
+func (store *BoltStore) WriteWatchSyncHeight(height uint32) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		return b.Put([]byte(WATCH_SYNC_KEY), heightKey(height))
+	})
+
+	return err
+}
+
+// Saves the tx. A tx that is not indexed yet is indexed as pending, an included tx keeps its height.
+func (store *BoltStore) WriteTransaction(txHash [32]byte, tx protocol.Transaction) (err error) {
+	bucket, err := txBucket(tx)
+	if err != nil {
+		return err
+	}
+
+	err = store.db.Update(func(boltTx *bolt.Tx) error {
 		b := boltTx.Bucket([]byte(bucket))
-		err := b.Put(txHash[:], tx.Encode())
+		if err := b.Put(txHash[:], tx.Encode()); err != nil {
+			return err
+		}
+
+		if boltTx.Bucket([]byte(TX_INDEX_BUCKET)).Get(txHash[:]) != nil {
+			return nil
+		}
+
+		return indexTx(boltTx, txHash, indexedAddresses(tx), PENDING_HEIGHT, [32]byte{})
+	})
 
+	return err
+}
+
+// Saves a tx verified to be included in the block and indexes it at the block's height.
+func (store *BoltStore) WriteIncludedTransaction(txHash [32]byte, tx protocol.Transaction, height uint32, blockHash [32]byte) (err error) {
+	bucket, err := txBucket(tx)
+	if err != nil {
 		return err
+	}
+
+	err = store.db.Update(func(boltTx *bolt.Tx) error {
+		b := boltTx.Bucket([]byte(bucket))
+		if err := b.Put(txHash[:], tx.Encode()); err != nil {
+			return err
+		}
+
+		return indexTx(boltTx, txHash, indexedAddresses(tx), height, blockHash)
 	})
 
 	return err
 }
+
+func (store *BoltStore) WriteTxStatus(status *TxStatus) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TX_STATUS_BUCKET))
+		return b.Put(status.TxHash[:], status.Encode())
+	})
+
+	return err
+}
+
+func (store *BoltStore) WriteTxStatusSyncHeight(height uint32) (err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(SYNC_BUCKET))
+		return b.Put([]byte(TX_STATUS_SYNC_KEY), heightKey(height))
+	})
+
+	return err
+}
+
+// Moves the tx included at or above the given height back to TX_SUBMITTED, so that these heights are tested
+// against them again. Called when a reorg rolls back the headers from height on.
+func (store *BoltStore) RollBackTxStatusesFrom(height uint32) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TX_STATUS_BUCKET))
+
+		var rolledBack []*TxStatus
+		b.ForEach(func(k, v []byte) error {
+			var status *TxStatus
+			if status = status.Decode(v); status != nil && (status.State == TX_INCLUDED || status.State == TX_VERIFIED) && status.Height >= height {
+				rolledBack = append(rolledBack, status)
+			}
+
+			return nil
+		})
+
+		for _, status := range rolledBack {
+			status.State = TX_SUBMITTED
+			status.BlockHash = [32]byte{}
+			status.Height = 0
+			status.Error = ""
+
+			if err := b.Put(status.TxHash[:], status.Encode()); err != nil {
+				return err
+			}
+		}
+
+		sb := tx.Bucket([]byte(SYNC_BUCKET))
+		if v := sb.Get([]byte(TX_STATUS_SYNC_KEY)); v != nil && binary.BigEndian.Uint32(v) > height {
+			return sb.Put([]byte(TX_STATUS_SYNC_KEY), heightKey(height))
+		}
+
+		return nil
+	})
+}
+
+// Moves the tx indexed at or above the given height back to pending. Called when a reorg rolls back the headers
+// from height on.
+func (store *BoltStore) RollBackTxIndexFrom(height uint32) {
+	store.db.Update(func(tx *bolt.Tx) error {
+		rolledBack := make(map[[32]byte]*txIndexEntry)
+		tx.Bucket([]byte(TX_INDEX_BUCKET)).ForEach(func(k, v []byte) error {
+			var entry *txIndexEntry
+			if entry = entry.Decode(v); entry != nil && entry.Height >= height && entry.Height != PENDING_HEIGHT {
+				var txHash [32]byte
+				copy(txHash[:], k)
+				rolledBack[txHash] = entry
+			}
+
+			return nil
+		})
+
+		for txHash, entry := range rolledBack {
+			if err := indexTx(tx, txHash, entry.Addresses, PENDING_HEIGHT, [32]byte{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
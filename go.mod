@@ -8,6 +8,7 @@ require (
 	github.com/gorilla/mux v1.7.4
 	github.com/urfave/cli v1.22.3
 	github.com/way365/bazo-miner v0.0.0-20200303120255-9fe62280f40b
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
 )
 
 require (
@@ -17,7 +18,6 @@ require (
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	github.com/willf/bitset v1.1.10 // indirect
 	github.com/willf/bloom v2.0.3+incompatible // indirect
-	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073 // indirect
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527 // indirect
 )
 
@@ -23,12 +23,11 @@ func GetAccountEndpoint(w http.ResponseWriter, req *http.Request) {
 	if len(param) == 64 {
 		copy(addressHash[:], pubKeyInt.Bytes())
 
-		network.AccReq(false, addressHash)
-
-		accI, _ := network.Fetch(network.AccChan)
-		acc := accI.(*protocol.Account)
-
-		address = acc.Address
+		if future, err := network.AccReq(false, addressHash); err == nil {
+			if accI, err := future.Get(); err == nil && accI.(*protocol.Account) != nil {
+				address = accI.(*protocol.Account).Address
+			}
+		}
 	} else if len(param) == 128 {
 		copy(address[:], pubKeyInt.Bytes())
 		addressHash = protocol.SerializeHashContent(address)
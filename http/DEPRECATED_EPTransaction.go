@@ -165,7 +165,7 @@ func sendTxEndpoint(w http.ResponseWriter, req *http.Request, txType int) {
 	case p2p.ACCTX_BRDCST:
 		if tx := services.UnsignedAccTx[txHash]; tx != nil {
 			tx.Sig = txSign
-			err = network.SendTx(util.Config.BootstrapIpport, tx, p2p.ACCTX_BRDCST)
+			err = network.BroadcastTx(tx, p2p.ACCTX_BRDCST).Err()
 
 			//If tx was successful or not, delete it from map either way. A new tx creation is the only option to repeat.
 			delete(services.UnsignedFundsTx, txHash)
@@ -176,7 +176,7 @@ func sendTxEndpoint(w http.ResponseWriter, req *http.Request, txType int) {
 	case p2p.CONFIGTX_BRDCST:
 		if tx := services.UnsignedConfigTx[txHash]; tx != nil {
 			tx.Sig = txSign
-			err = network.SendTx(util.Config.BootstrapIpport, tx, p2p.CONFIGTX_BRDCST)
+			err = network.BroadcastTx(tx, p2p.CONFIGTX_BRDCST).Err()
 
 			//If tx was successful or not, delete it from map either way. A new tx creation is the only option to repeat.
 			delete(services.UnsignedFundsTx, txHash)
@@ -194,7 +194,7 @@ func sendTxEndpoint(w http.ResponseWriter, req *http.Request, txType int) {
 				}
 			} else {
 				tx.Sig2 = txSign
-				err = network.SendTx(util.Config.BootstrapIpport, tx, p2p.FUNDSTX_BRDCST)
+				err = network.BroadcastTx(tx, p2p.FUNDSTX_BRDCST).Err()
 				delete(services.UnsignedFundsTx, txHash)
 			}
 		} else {
@@ -3,12 +3,14 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/services"
+	"math"
 	"net/http"
+	"strconv"
 )
 
-func PostAccountTx(w http.ResponseWriter, req *http.Request) {
+func (endpoints *restEndpoints) PostAccountTx(w http.ResponseWriter, req *http.Request) {
 	logger.Println("Incoming create account request")
 	decoder := json.NewDecoder(req.Body)
 	var createAccountArgs args.CreateAccountArgs
@@ -25,7 +27,7 @@ func PostAccountTx(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	txHash, _, err := services.PrepareCreateAccountTx(&createAccountArgs, logger)
+	txHash, _, err := endpoints.client.PrepareCreateAccountTx(&createAccountArgs, logger)
 	if err != nil {
 		panic(err)
 	}
@@ -38,3 +40,62 @@ func PostAccountTx(w http.ResponseWriter, req *http.Request) {
 
 	SendJsonResponse(w, JsonResponse{http.StatusOK, "AccountTx successfully created. Sign the provided hash.", responseBody})
 }
+
+// Query parameters from, to, page and pagesize select the page, see args.AccountHistoryArgs.
+func (endpoints *restEndpoints) GetAccountHistory(w http.ResponseWriter, req *http.Request) {
+	historyArgs := args.AccountHistoryArgs{
+		CheckAccountArgs: args.CheckAccountArgs{Address: mux.Vars(req)["address"]},
+		To:               math.MaxUint32,
+		Page:             1,
+		PageSize:         args.DEFAULT_HISTORY_PAGE_SIZE,
+	}
+
+	err := parseHistoryQuery(req, &historyArgs)
+	if err == nil {
+		err = historyArgs.ValidateInput()
+	}
+
+	if err != nil {
+		fmt.Printf("%v", err)
+		SendJsonResponse(w, JsonResponse{http.StatusBadRequest, "Invalid arguments", []Content{}})
+		return
+	}
+
+	history, err := endpoints.client.GetAccountHistory(&historyArgs)
+	if err != nil {
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, err.Error(), []Content{}})
+		return
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Transactions of the account", []Content{{"History", history}}})
+}
+
+func parseHistoryQuery(req *http.Request, historyArgs *args.AccountHistoryArgs) (err error) {
+	query := req.URL.Query()
+
+	if v := query.Get("from"); v != "" {
+		if historyArgs.From, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		if historyArgs.To, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	if v := query.Get("page"); v != "" {
+		if historyArgs.Page, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	}
+
+	if v := query.Get("pagesize"); v != "" {
+		if historyArgs.PageSize, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -4,11 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/services"
 	"net/http"
 )
 
-func PostFundsTx(w http.ResponseWriter, req *http.Request) {
+func (endpoints *restEndpoints) PostFundsTx(w http.ResponseWriter, req *http.Request) {
 	logger.Println("Incoming createFunds request")
 	decoder := json.NewDecoder(req.Body)
 	var fundsArgs args.FundsArgs
@@ -25,7 +24,7 @@ func PostFundsTx(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	txHash, _, err := services.PrepareFundsTx(&fundsArgs, logger)
+	txHash, _, err := endpoints.client.PrepareFundsTx(&fundsArgs, logger)
 	if err != nil {
 		panic(err)
 	}
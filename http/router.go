@@ -1,37 +1,68 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/way365/bazo-client/services"
 	"github.com/way365/bazo-client/util"
 	"log"
 	"net/http"
+	"time"
 )
 
+// Seconds the requests in flight are given to complete on shutdown.
+const SHUTDOWN_TIMEOUT = 10
+
 var (
 	logger *log.Logger
 )
 
+// The handlers of the REST API, they serve the client's state.
+type restEndpoints struct {
+	client *services.Client
+}
+
 type SignatureResponseBody struct {
 	TxHash    string `json:"hash"`
 	Signature string `json:"signature"`
 }
 
-func Init() {
+// Serves the client's REST API until ctx is done. Requests in flight are completed before Init returns.
+func Init(ctx context.Context, client *services.Client) error {
 	logger = util.InitLogger()
 
 	logger.Printf("%v\n\n", "Starting rest...")
 
 	router := mux.NewRouter()
-	getEndpoints(router)
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
+	getEndpoints(router, &restEndpoints{client})
+	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
 	ignoreOptions := handlers.IgnoreOptions()
 
-	log.Fatal(http.ListenAndServe(":"+util.Config.Thisclient.Port, handlers.CORS(methodsOk, ignoreOptions)(router)))
+	server := &http.Server{
+		Addr:    ":" + util.Config.Thisclient.Port,
+		Handler: handlers.CORS(methodsOk, ignoreOptions)(router),
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		logger.Println("Stopping rest...")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return <-shutdown
 }
 
-func getEndpoints(router *mux.Router) {
+func getEndpoints(router *mux.Router, endpoints *restEndpoints) {
 	// Old code. Needs to be updated.
 	//router.HandleFunc("/account/{id}", GetAccountEndpoint).Methods("GET")
 	//
@@ -42,10 +73,18 @@ func getEndpoints(router *mux.Router) {
 	//router.HandleFunc("/createConfigTx/{header}/{id}/{payload}/{fee}/{txCnt}", CreateConfigTxEndpoint).Methods("POST")
 	//router.HandleFunc("/sendConfigTx/{txHash}/{txSign}", SendConfigTxEndpoint).Methods("POST")
 
-	router.HandleFunc("/tx/acc", PostAccountTx).Methods("POST")
-	router.HandleFunc("/tx/funds", PostFundsTx).Methods("POST")
-	router.HandleFunc("/tx/update", PostUpdateTx).Methods("POST")
-	router.HandleFunc("/tx/signature", PostSignTx).Methods("POST")
+	router.HandleFunc("/account/{address}/history", endpoints.GetAccountHistory).Methods("GET")
+
+	router.HandleFunc("/tx/acc", endpoints.PostAccountTx).Methods("POST")
+	router.HandleFunc("/tx/funds", endpoints.PostFundsTx).Methods("POST")
+	router.HandleFunc("/tx/update", endpoints.PostUpdateTx).Methods("POST")
+	router.HandleFunc("/tx/signature", endpoints.PostSignTx).Methods("POST")
+	router.HandleFunc("/tx/{hash}/status", endpoints.GetTxStatus).Methods("GET")
+
+	router.HandleFunc("/watchlist", endpoints.GetWatchList).Methods("GET")
+	router.HandleFunc("/watchlist", endpoints.PostWatchedAddress).Methods("POST")
+	router.HandleFunc("/watchlist/{address}", endpoints.DeleteWatchedAddress).Methods("DELETE")
+	router.HandleFunc("/watchlist/{address}/activity", endpoints.GetWatchActivity).Methods("GET")
 
 }
 
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/way365/bazo-client/args"
+	"net/http"
+)
+
+func (endpoints *restEndpoints) GetTxStatus(w http.ResponseWriter, req *http.Request) {
+	txStatusArgs := args.TxStatusArgs{Hash: mux.Vars(req)["hash"]}
+
+	err := txStatusArgs.ValidateInput()
+	if err != nil {
+		fmt.Printf("%v", err)
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, "Invalid arguments", []Content{}})
+		return
+	}
+
+	status, err := endpoints.client.GetTxStatus(txStatusArgs.ResolveHash())
+	if err != nil {
+		SendJsonResponse(w, JsonResponse{http.StatusNotFound, err.Error(), []Content{}})
+		return
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Transaction status", []Content{{"Status", status}}})
+}
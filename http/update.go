@@ -4,11 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/services"
 	"net/http"
 )
 
-func PostUpdateTx(w http.ResponseWriter, req *http.Request) {
+func (endpoints *restEndpoints) PostUpdateTx(w http.ResponseWriter, req *http.Request) {
 	logger.Println("Incoming create update request")
 	decoder := json.NewDecoder(req.Body)
 	var updateTxArgs args.UpdateTxArgs
@@ -25,7 +24,7 @@ func PostUpdateTx(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	txHash, _, err := services.PrepareUpdateTx(&updateTxArgs, logger)
+	txHash, _, err := endpoints.client.PrepareUpdateTx(&updateTxArgs, logger)
 	if err != nil {
 		panic(err)
 	}
@@ -3,14 +3,12 @@ package http
 import (
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/way365/bazo-client/cstorage"
 	"github.com/way365/bazo-client/services"
 	"net/http"
 )
 
-func PostSignTx(w http.ResponseWriter, req *http.Request) {
+func (endpoints *restEndpoints) PostSignTx(w http.ResponseWriter, req *http.Request) {
 	logger.Println("Incoming sign tx request")
 	decoder := json.NewDecoder(req.Body)
 	var requestBody SignatureResponseBody
@@ -32,16 +30,7 @@ func PostSignTx(w http.ResponseWriter, req *http.Request) {
 	copy(txHash[:], txHashBytes[:])
 	copy(Signature[:], signatureBytes[:])
 
-	tx := cstorage.ReadTransaction(txHash)
-	if tx == nil {
-		panic(errors.New("transaction not found"))
-	}
-
-	tx.SetSignature(Signature)
-
-	services.SubmitTx(txHash, tx)
-
-	cstorage.WriteTransaction(txHash, tx)
+	result, err := endpoints.client.SubmitSignedTx(txHash, Signature)
 
 	var responseBody []Content
 	var txResponse Content
@@ -50,5 +39,16 @@ func PostSignTx(w http.ResponseWriter, req *http.Request) {
 	txResponse.Detail = fmt.Sprintf("%x", txHash)
 	responseBody = append(responseBody, txResponse)
 
+	if result != nil {
+		for _, submission := range services.ConvertBroadcastResult(result) {
+			responseBody = append(responseBody, Content{"Submission", submission})
+		}
+	}
+
+	if err != nil {
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, err.Error(), responseBody})
+		return
+	}
+
 	SendJsonResponse(w, JsonResponse{http.StatusOK, "Tx successfully sent to network.", responseBody})
 }
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/way365/bazo-client/args"
+	"net/http"
+)
+
+func (endpoints *restEndpoints) GetWatchList(w http.ResponseWriter, req *http.Request) {
+	var responseBody []Content
+	for _, address := range endpoints.client.GetWatchedAddresses() {
+		responseBody = append(responseBody, Content{"Address", address})
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Watched addresses", responseBody})
+}
+
+func (endpoints *restEndpoints) PostWatchedAddress(w http.ResponseWriter, req *http.Request) {
+	logger.Println("Incoming watch address request")
+	decoder := json.NewDecoder(req.Body)
+	var watchArgs args.WatchArgs
+
+	err := decoder.Decode(&watchArgs)
+	if err != nil {
+		SendJsonResponse(w, JsonResponse{http.StatusBadRequest, "Invalid request body", []Content{}})
+		return
+	}
+
+	err = endpoints.client.AddWatchedAddress(&watchArgs, logger)
+	if err != nil {
+		fmt.Printf("%v", err)
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, "Invalid arguments", []Content{}})
+		return
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Address added to the watch list.", []Content{{"Address", watchArgs.Address}}})
+}
+
+func (endpoints *restEndpoints) DeleteWatchedAddress(w http.ResponseWriter, req *http.Request) {
+	logger.Println("Incoming unwatch address request")
+	watchArgs := args.WatchArgs{Address: mux.Vars(req)["address"]}
+
+	err := endpoints.client.RemoveWatchedAddress(&watchArgs, logger)
+	if err != nil {
+		fmt.Printf("%v", err)
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, "Invalid arguments", []Content{}})
+		return
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Address removed from the watch list.", []Content{{"Address", watchArgs.Address}}})
+}
+
+func (endpoints *restEndpoints) GetWatchActivity(w http.ResponseWriter, req *http.Request) {
+	watchArgs := args.WatchArgs{Address: mux.Vars(req)["address"]}
+
+	err := watchArgs.ValidateInput()
+	if err != nil {
+		fmt.Printf("%v", err)
+		SendJsonResponse(w, JsonResponse{http.StatusInternalServerError, "Invalid arguments", []Content{}})
+		return
+	}
+
+	var responseBody []Content
+	for _, activity := range endpoints.client.GetWatchActivity(watchArgs.ResolveAddress()) {
+		responseBody = append(responseBody, Content{"Activity", activity})
+	}
+
+	SendJsonResponse(w, JsonResponse{http.StatusOK, "Verified activity of the watched address", responseBody})
+}
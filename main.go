@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	cli2 "github.com/urfave/cli"
 	"github.com/way365/bazo-client/cli"
 	"github.com/way365/bazo-client/cstorage"
@@ -9,6 +10,8 @@ import (
 	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/p2p"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -17,24 +20,63 @@ func main() {
 	logger := util.InitLogger()
 	util.Config = util.LoadConfiguration()
 
-	network.Init()
-	cstorage.Init("client.db")
+	//SIGINT and SIGTERM cancel the context, which stops the services and closes the connections.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	store, err := cstorage.Open(util.Config.Storage.Backend, util.Config.Storage.Path)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	client := services.NewClient(store)
 
 	app := cli2.NewApp()
 
 	app.Name = "bazo-client"
 	app.Usage = "the command line interface for interacting with the Bazo blockchain implemented in Go."
 	app.Version = "1.0.0"
+	app.Flags = []cli2.Flag{
+		cli2.StringFlag{
+			Name:  "record",
+			Usage: "record every message exchanged with the miners to `FILE`",
+		},
+	}
+	app.Before = func(c *cli2.Context) error {
+		if c.IsSet("record") {
+			if err := network.StartRecording(c.String("record")); err != nil {
+				return err
+			}
+		}
+
+		//A replay does not connect to the network, the recorded miners answer instead. Backups only touch the DB.
+		if command := c.Args().First(); command != "debug" && command != "db" {
+			network.Init(ctx)
+		}
+
+		return nil
+	}
 	app.Commands = []cli2.Command{
-		cli.GetAccountCommand(logger),
-		cli.GetFundsCommand(logger),
-		cli.GetNetworkCommand(logger),
-		cli.GetRestCommand(),
-		cli.GetStakingCommand(logger),
-		cli.GetUpdateTxCommand(logger),
+		cli.GetAccountCommand(ctx, client, logger),
+		cli.GetDbCommand(client, logger),
+		cli.GetDebugCommand(ctx, logger),
+		cli.GetFundsCommand(client, logger),
+		cli.GetNetworkCommand(ctx, client, logger),
+		cli.GetRestCommand(ctx, client),
+		cli.GetStakingCommand(client, logger),
+		cli.GetTxCommand(ctx, client, logger),
+		cli.GetUpdateTxCommand(client, logger),
+		cli.GetWatchCommand(ctx, client, logger),
 	}
 
-	err := app.Run(os.Args)
+	err = app.Run(os.Args)
+
+	//Shut down in reverse order: the services stop writing to the DB before it is closed.
+	stop()
+	client.Wait()
+	network.Wait()
+	network.StopRecording()
+	store.Close()
+
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -0,0 +1,209 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The outcome of submitting a tx to a single miner.
+type TxSubmission struct {
+	Peer     string
+	Accepted bool
+	Attempts int
+	Time     time.Time //When the last attempt was made
+	Err      error
+}
+
+// The outcomes of submitting a tx to several miners. The tx is sent if at least one miner accepted it.
+type BroadcastResult struct {
+	TxHash      [32]byte
+	Submissions []*TxSubmission
+
+	//Set if the tx was not submitted because no miner is known.
+	err error
+}
+
+func (result *BroadcastResult) Accepted() (accepted int) {
+	for _, submission := range result.Submissions {
+		if submission.Accepted {
+			accepted++
+		}
+	}
+
+	return accepted
+}
+
+// Returns nil if a miner accepted the tx, otherwise an error listing why each miner did not.
+func (result *BroadcastResult) Err() error {
+	if result.err != nil {
+		return result.err
+	}
+
+	if result.Accepted() > 0 {
+		return nil
+	}
+
+	var reasons []string
+	for _, submission := range result.Submissions {
+		reasons = append(reasons, fmt.Sprintf("%v: %v", submission.Peer, submission.Err))
+	}
+
+	return errors.New(fmt.Sprintf("Sending tx %x failed, no miner accepted it (%v).", result.TxHash[:8], strings.Join(reasons, "; ")))
+}
+
+func (result *BroadcastResult) String() string {
+	str := fmt.Sprintf("Tx %x accepted by %v of %v miners", result.TxHash[:8], result.Accepted(), len(result.Submissions))
+	for _, submission := range result.Submissions {
+		if submission.Accepted {
+			str += fmt.Sprintf("\n%v: accepted after %v attempt(s)", submission.Peer, submission.Attempts)
+		} else {
+			str += fmt.Sprintf("\n%v: not accepted after %v attempt(s): %v", submission.Peer, submission.Attempts, submission.Err)
+		}
+	}
+
+	return str
+}
+
+// Submits the tx to up to util.Config.BroadcastPeers miners in parallel, see broadcastTargets. If fewer miners are
+// known, the tx is submitted to those. Miners that cannot be reached or time out are retried up to
+// util.BROADCAST_ATTEMPTS times, miners rejecting the tx are not. The result's Err is nil if any miner accepted the tx.
+func BroadcastTx(tx protocol.Transaction, typeID uint8) *BroadcastResult {
+	result := &BroadcastResult{TxHash: tx.Hash()}
+	packet := p2p.BuildPacket(typeID, tx.Encode())
+
+	targets := broadcastTargets()
+	if len(targets) == 0 {
+		result.err = errors.New(fmt.Sprintf("Sending tx %x failed, no miner is known.", result.TxHash[:8]))
+		return result
+	}
+
+	if len(targets) < util.Config.BroadcastPeers {
+		logger.Printf("Submitting tx %x to %v of %v miners, no further miners are known: %v\n",
+			result.TxHash[:8],
+			len(targets),
+			util.Config.BroadcastPeers,
+			strings.Join(targets, ", "))
+	}
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		submission := &TxSubmission{Peer: target}
+		result.Submissions = append(result.Submissions, submission)
+
+		wg.Add(1)
+		go func(submission *TxSubmission) {
+			defer wg.Done()
+
+			for submission.Attempts < util.BROADCAST_ATTEMPTS {
+				submission.Attempts++
+				submission.Time = time.Now()
+
+				var rejected bool
+				rejected, submission.Err = submitTx(submission.Peer, packet)
+				if submission.Err == nil {
+					submission.Accepted = true
+					return
+				}
+
+				if rejected {
+					return
+				}
+
+				time.Sleep(time.Duration(util.RECONNECT_BACKOFF_MIN<<uint(submission.Attempts-1)) * time.Second)
+			}
+		}(submission)
+	}
+
+	wg.Wait()
+
+	return result
+}
+
+// Up to util.Config.BroadcastPeers miners: the bootstrap miner first, then the connected miners, then the known
+// miners that are not connected, which are dialed for the submission. One-shot commands are only connected to the
+// bootstrap miner, if too few miners are known the neighbors of a connected miner are requested first.
+func broadcastTargets() (targets []string) {
+	targets = knownTargets()
+	if len(targets) >= util.Config.BroadcastPeers {
+		return targets
+	}
+
+	future, err := request(p2p.NEIGHBOR_REQ, p2p.NEIGHBOR_RES, nil, nil)
+	if err != nil {
+		logger.Printf("Requesting neighbors to submit to failed: %v\n", err)
+		return targets
+	}
+
+	//The neighbors are added to the candidates when the response is processed.
+	if _, err := future.Get(); err != nil {
+		logger.Printf("Requesting neighbors to submit to failed: %v\n", err)
+		return targets
+	}
+
+	return knownTargets()
+}
+
+func knownTargets() (targets []string) {
+	add := func(ipport string) {
+		if len(targets) >= util.Config.BroadcastPeers || ipport == "" {
+			return
+		}
+
+		for _, target := range targets {
+			if target == ipport {
+				return
+			}
+		}
+
+		targets = append(targets, ipport)
+	}
+
+	add(util.Config.BootstrapIpport)
+
+	for _, p := range peers.getAllPeers() {
+		add(p.dial)
+	}
+
+	for _, ipport := range candidates.dialable() {
+		add(ipport)
+	}
+
+	return targets
+}
+
+// Sends a tx packet over a new connection and waits for the miner's answer. rejected is set if the miner answered
+// and refused the tx, as opposed to the miner not being reachable.
+func submitTx(ipport string, packet []byte) (rejected bool, err error) {
+	conn, err := dialPeer(ipport)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(util.FETCH_TIMEOUT * time.Second))
+	if _, err := conn.Write(packet); err != nil {
+		return false, err
+	}
+
+	header, payload, err := p2p.RcvData_(conn)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return false, errors.New("timed out")
+		}
+
+		return false, err
+	}
+
+	if header.TypeID == p2p.NOT_FOUND {
+		return true, errors.New(fmt.Sprintf("rejected: %v", string(payload[:])))
+	}
+
+	return false, nil
+}
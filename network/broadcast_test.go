@@ -0,0 +1,110 @@
+package network
+
+import (
+	"github.com/way365/bazo-client/network/minertest"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKnownTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		peers      int
+		want       []string //The bootstrap miner first, the candidates in any order
+	}{
+		{"bootstrap only", nil, 3, []string{"10.0.0.1:8000"}},
+		{"candidates fill up", []string{"10.0.0.2:8000", "10.0.0.3:8000"}, 3, []string{"10.0.0.1:8000", "10.0.0.2:8000", "10.0.0.3:8000"}},
+		{"bootstrap candidate listed once", []string{"10.0.0.1:8000", "10.0.0.2:8000"}, 3, []string{"10.0.0.1:8000", "10.0.0.2:8000"}},
+		{"limited to broadcast peers", []string{"10.0.0.2:8000", "10.0.0.3:8000"}, 2, nil},
+	}
+
+	config := util.Config
+	defer func() { util.Config = config }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.Config.BootstrapIpport = "10.0.0.1:8000"
+			util.Config.BroadcastPeers = test.peers
+
+			candidates.ipports = make(map[string]*candidate)
+			defer func() { candidates.ipports = make(map[string]*candidate) }()
+			for _, ipport := range test.candidates {
+				candidates.add(ipport)
+			}
+
+			targets := knownTargets()
+			if len(targets) > test.peers || len(targets) == 0 || targets[0] != util.Config.BootstrapIpport {
+				t.Fatalf("knownTargets() = %v, want the bootstrap miner first and at most %v", targets, test.peers)
+			}
+
+			if test.want == nil {
+				if len(targets) != test.peers {
+					t.Errorf("knownTargets() = %v, want %v targets", targets, test.peers)
+				}
+
+				return
+			}
+
+			sort.Strings(targets[1:])
+			if !reflect.DeepEqual(targets, test.want) {
+				t.Errorf("knownTargets() = %v, want %v", targets, test.want)
+			}
+		})
+	}
+}
+
+func TestBroadcastTxWithFewerMinersThanBroadcastPeers(t *testing.T) {
+	m, err := minertest.NewMiner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	config := util.Config
+	defer func() { util.Config = config }()
+
+	candidates.ipports = make(map[string]*candidate)
+
+	//The default of 3 broadcast peers with the single miner of the default configuration.
+	util.Config.BootstrapIpport = m.Addr()
+	util.Config.BroadcastPeers = 3
+
+	tx := &protocol.FundsTx{Amount: 1}
+	result := BroadcastTx(tx, p2p.FUNDSTX_BRDCST)
+	if err := result.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Submissions) != 1 || result.Accepted() != 1 {
+		t.Errorf("Tx accepted by %v of %v miners, want 1 of 1", result.Accepted(), len(result.Submissions))
+	}
+
+	if received := m.ReceivedTxs(); len(received) != 1 || received[0].Hash() != tx.Hash() {
+		t.Errorf("Miner received %v, want the tx", received)
+	}
+}
+
+func TestBroadcastTxFailsWithoutMiners(t *testing.T) {
+	config := util.Config
+	defer func() { util.Config = config }()
+
+	candidates.ipports = make(map[string]*candidate)
+
+	util.Config.BootstrapIpport = ""
+	util.Config.BroadcastPeers = 3
+
+	//No miner is connected to ask for neighbors, nothing is dialed.
+	result := BroadcastTx(new(protocol.FundsTx), p2p.FUNDSTX_BRDCST)
+	if result.Err() == nil {
+		t.Error("Broadcast without a known miner succeeded, want an error")
+	}
+
+	if len(result.Submissions) != 0 {
+		t.Errorf("Tx submitted to %v miners, want none", len(result.Submissions))
+	}
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/way365/bazo-client/util"
@@ -8,47 +9,86 @@ import (
 	"log"
 	"net"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	logger     *log.Logger
-	peers      peersStruct
+	peers      = &peersStruct{minerConns: make(map[*peer]bool)}
 	register   = make(chan *peer)
 	disconnect = make(chan *peer)
+
+	//Tracks the goroutines of the package, so that Wait can block until they returned.
+	running sync.WaitGroup
 )
 
-func Init() {
+// Connects to the network. Once ctx is done, all connections are closed and the pending requests fail.
+func Init(ctx context.Context) {
 	logger = util.InitLogger()
-	peers.minerConns = make(map[*peer]bool)
 
-	go peerService()
-	// TODO Enable again
-	//go checkHealthService()
+	running.Add(1)
+	go peerService(ctx)
+
+	//Connect to the bootstrap miner right away, one-shot commands need a connection before the health service runs.
+	candidates.add(util.Config.BootstrapIpport)
+	if !connect(ctx, util.Config.BootstrapIpport) {
+		logger.Println("Initiating new network connection failed, retrying in the background.")
+	}
+
+	running.Add(1)
+	go checkHealthService(ctx)
+}
+
+// Blocks until the connections are closed after the context passed to Init is done.
+func Wait() {
+	running.Wait()
+}
 
-	p, err := initiateNewClientConnection(util.Config.BootstrapIpport)
+// Dials a miner and starts serving the connection. Failed attempts back the address off.
+func connect(ctx context.Context, ipport string) bool {
+	p, err := initiateNewClientConnection(ipport)
 	if err != nil {
-		logger.Fatal("Initiating new network connection failed: ", err)
+		logger.Printf("Connecting to %v failed: %v\n", ipport, err)
+		candidates.failed(ipport)
+		return false
 	}
 
-	go minerConn(p)
+	candidates.connected(ipport)
+
+	running.Add(1)
+	go minerConn(ctx, p)
+
+	return true
 }
 
 func initiateNewClientConnection(dial string) (*peer, error) {
 	var conn net.Conn
 
+	if isFlagged(dial) {
+		return nil, errors.New(fmt.Sprintf("Peer %v is flagged, connection refused", dial))
+	}
+
+	_, port, err := net.SplitHostPort(dial)
+	if err != nil {
+		return nil, err
+	}
+
 	//Open up a tcp dial and instantiate a peer struct, wait for adding it to the peerStruct before we finalize
 	//the handshake
-	conn, err := net.Dial("tcp", dial)
+	conn, err = dialPeer(dial)
 	if err != nil {
 		return nil, err
 	}
 
-	conn.(*net.TCPConn).SetKeepAlive(true)
-	conn.(*net.TCPConn).SetKeepAlivePeriod(1 * time.Minute)
+	p := newPeer(conn, port)
+	p.dial = dial
 
-	p := newPeer(conn, strings.Split(dial, ":")[1])
+	//A peer dialed by hostname may have been flagged by its address.
+	if isFlagged(p.getIPPort()) {
+		conn.Close()
+		return nil, errors.New(fmt.Sprintf("Peer %v is flagged, connection refused", p.getIPPort()))
+	}
 
 	localPort, _ := strconv.Atoi(util.Config.Thisclient.Port)
 	packet, err := p2p.PrepareHandshake(p2p.CLIENT_PING, localPort)
@@ -56,36 +96,56 @@ func initiateNewClientConnection(dial string) (*peer, error) {
 		return nil, err
 	}
 
+	record(p, true, p2p.CLIENT_PING, packet[p2p.HEADER_LEN:])
 	conn.Write(packet)
 
-	//Wait for the other party to finish the handshake with the corresponding message
+	//Wait for the other party to finish the handshake with the corresponding message. A miner that does not
+	//answer must not block the shutdown.
+	timer := time.AfterFunc(util.FETCH_TIMEOUT*time.Second, func() { conn.Close() })
 	header, _, err := rcvData(p)
+	timer.Stop()
 	if err != nil || header.TypeID != p2p.CLIENT_PONG {
+		conn.Close()
 		return nil, errors.New(fmt.Sprintf("Failed to complete network handshake: %v", err))
 	}
 
 	return p, nil
 }
 
-func minerConn(p *peer) {
+func minerConn(ctx context.Context, p *peer) {
+	defer running.Done()
+
 	logger.Printf("Adding a new miner: %v\n", p.getIPPort())
 
 	//Give the peer a channel
 	p.ch = make(chan []byte)
 
 	//Register withe the broadcast service and start the additional writer
-	register <- p
+	select {
+	case register <- p:
+	case <-ctx.Done():
+		p.conn.Close()
+		return
+	}
 
 	for {
 		header, payload, err := rcvData(p)
 		if err != nil {
 			logger.Printf("Miner disconnected: %v\n", err)
 
-			//In case of a comm fail, disconnect cleanly from the broadcast service
-			disconnect <- p
+			//In case of a comm fail, disconnect cleanly from the broadcast service. After shutdown, the
+			//broadcast service is gone.
+			select {
+			case disconnect <- p:
+			case <-ctx.Done():
+				peers.delete(p)
+				pending.cancel(p)
+			}
+
+			candidates.failed(p.dial)
 			return
 		}
 
-		processIncomingMsg(p, header, payload)
+		processIncomingMsg(ctx, p, header, payload)
 	}
 }
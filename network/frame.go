@@ -0,0 +1,80 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-miner/p2p"
+	"sync"
+)
+
+var (
+	ErrFrameOversized = errors.New("payload exceeds the maximum size of its message type")
+	ErrFrameTruncated = errors.New("connection closed within the frame")
+	ErrFrameTimeout   = errors.New("frame not received within the read deadline")
+)
+
+// Maximum payload size per message type. Types not listed are limited to DEFAULT_MAX_PAYLOAD_SIZE.
+const DEFAULT_MAX_PAYLOAD_SIZE = 64 << 10
+
+var maxPayloadSizes = map[uint8]uint32{
+	p2p.BLOCK_RES:    16 << 20,
+	p2p.FUNDSTX_RES:  1 << 20,
+	p2p.ACCTX_RES:    1 << 20,
+	p2p.CONFIGTX_RES: 1 << 20,
+	p2p.STAKETX_RES:  1 << 20,
+}
+
+// Misbehavior points per frame error. A peer reaching MAX_MISBEHAVIOR_SCORE is flagged.
+const MAX_MISBEHAVIOR_SCORE = 100
+
+var misbehaviorPoints = map[error]int{
+	ErrFrameOversized: MAX_MISBEHAVIOR_SCORE,
+	ErrFrameTruncated: 25,
+	ErrFrameTimeout:   10,
+}
+
+// A frame that could not be read. Reason is one of the ErrFrame errors.
+type FrameError struct {
+	Peer   string
+	TypeID uint8
+	Len    uint32
+	Reason error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("Frame of type %v with length %v from %v rejected: %v", p2p.LogMapping[e.TypeID], e.Len, e.Peer, e.Reason)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Reason
+}
+
+func maxPayloadSize(typeID uint8) uint32 {
+	if size, ok := maxPayloadSizes[typeID]; ok {
+		return size
+	}
+
+	return DEFAULT_MAX_PAYLOAD_SIZE
+}
+
+// Misbehavior score per peer address. Scores are kept across reconnects.
+type misbehaviorStruct struct {
+	scores map[string]int
+	l      sync.Mutex
+}
+
+var misbehavior = misbehaviorStruct{scores: make(map[string]int)}
+
+// Adds the points of a frame error to the peer's score and flags the peer once the score reaches MAX_MISBEHAVIOR_SCORE.
+func (misbehavior *misbehaviorStruct) record(frameErr *FrameError) {
+	misbehavior.l.Lock()
+	misbehavior.scores[frameErr.Peer] += misbehaviorPoints[frameErr.Reason]
+	score := misbehavior.scores[frameErr.Peer]
+	misbehavior.l.Unlock()
+
+	logger.Printf("%v (misbehavior score %v)\n", frameErr, score)
+
+	if score >= MAX_MISBEHAVIOR_SCORE {
+		FlagPeer(frameErr.Peer, frameErr)
+	}
+}
@@ -1,32 +1,32 @@
 package network
 
 import (
+	"context"
 	"github.com/way365/bazo-miner/p2p"
 	"github.com/way365/bazo-miner/protocol"
 )
 
 var (
 	Uptodate      = false
-	BlockHeaderIn = make(chan *protocol.Block)
-	iplistChan    = make(chan string, p2p.MIN_MINERS)
-
-	BlockChan             = make(chan interface{})
-	BlockHeaderChan       = make(chan interface{})
-	FundsTxChan           = make(chan interface{})
-	AccTxChan             = make(chan interface{})
-	ConfigTxChan          = make(chan interface{})
-	StakeTxChan           = make(chan interface{})
-	AccChan               = make(chan interface{})
-	IntermediateNodesChan = make(chan [][32]byte)
+	BlockHeaderIn = make(chan *ReceivedBlockHeader)
 )
 
-func processIncomingMsg(p *peer, header *p2p.Header, payload []byte) {
+// A block header together with the address of the peer it was received from, so that the peer can be flagged
+// if the header turns out to be invalid.
+type ReceivedBlockHeader struct {
+	Header *protocol.Block
+	Peer   string
+}
+
+func processIncomingMsg(ctx context.Context, p *peer, header *p2p.Header, payload []byte) {
 	switch header.TypeID {
 	//BROADCAST
 	case p2p.BLOCK_HEADER_BRDCST:
 		//Prevent channel from blocking. Otherwise the client cannot proceed updating the headers!
+		//The header is handed over in the background, validating it requests the block from the peers and their
+		//responses have to be read meanwhile.
 		if Uptodate {
-			blockHeaderBrdcst(p, payload)
+			go blockHeaderBrdcst(ctx, p, payload)
 		} else {
 			logger.Println("Broadcasted block header not processed.")
 		}
@@ -45,9 +45,9 @@ func processIncomingMsg(p *peer, header *p2p.Header, payload []byte) {
 	case p2p.STAKETX_RES:
 		txRes(p, payload, p2p.STAKETX_RES)
 	case p2p.ACC_RES:
-		accRes(p, payload)
+		accRes(p, payload, p2p.ACC_RES)
 	case p2p.ROOTACC_RES:
-		accRes(p, payload)
+		accRes(p, payload, p2p.ROOTACC_RES)
 	case p2p.INTERMEDIATE_NODES_RES:
 		intermediateNodesRes(p, payload)
 	case p2p.NEIGHBOR_RES:
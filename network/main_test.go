@@ -0,0 +1,15 @@
+package network
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	//Init is not called by the tests, they log nowhere.
+	logger = log.New(io.Discard, "", 0)
+
+	os.Exit(m.Run())
+}
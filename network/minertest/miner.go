@@ -0,0 +1,458 @@
+// Package minertest provides an in-process stand-in for a bazo-miner, so that the client's network, sync, state and
+// submit flows can be exercised without a live miner. The miner answers requests from an in-memory chain and can
+// be scripted per message type.
+//
+//	m, _ := minertest.NewMiner()
+//	defer m.Close()
+//	m.AddBlock(genesis)
+//	util.Config.BootstrapIpport = m.Addr()
+//	network.Init(ctx)
+//	client := services.NewClient(cstorage.NewMemoryStore())
+package minertest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Answers a request. A resType of 0 sends no answer, e.g. to make the client time out.
+type HandlerFunc func(payload []byte) (resType uint8, resPayload []byte)
+
+type Miner struct {
+	listener net.Listener
+
+	chain     []*protocol.Block
+	blocks    map[[32]byte]*protocol.Block
+	txs       map[[32]byte]protocol.Transaction
+	accounts  map[[32]byte]*protocol.Account
+	neighbors []string
+	received  []protocol.Transaction
+	handlers  map[uint8]HandlerFunc
+	conns     map[net.Conn]bool
+
+	//Called for every tx broadcast, a non-nil error rejects the tx.
+	AcceptTx func(tx protocol.Transaction) error
+
+	l sync.Mutex
+}
+
+// Starts a miner listening on a random local port.
+func NewMiner() (*Miner, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Miner{
+		listener: listener,
+		blocks:   make(map[[32]byte]*protocol.Block),
+		txs:      make(map[[32]byte]protocol.Transaction),
+		accounts: make(map[[32]byte]*protocol.Account),
+		handlers: make(map[uint8]HandlerFunc),
+		conns:    make(map[net.Conn]bool),
+	}
+
+	go m.serve()
+
+	return m, nil
+}
+
+// The address to configure as bootstrap server.
+func (m *Miner) Addr() string {
+	return m.listener.Addr().String()
+}
+
+// Stops listening and closes all connections.
+func (m *Miner) Close() {
+	m.listener.Close()
+	m.DisconnectAll()
+}
+
+func (m *Miner) DisconnectAll() {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	for conn := range m.conns {
+		conn.Close()
+	}
+}
+
+// Appends the block to the chain. The block's txs are served as well.
+func (m *Miner) AddBlock(block *protocol.Block, txs ...protocol.Transaction) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.chain = append(m.chain, block)
+	m.blocks[block.Hash] = block
+	for _, tx := range txs {
+		m.txs[tx.Hash()] = tx
+	}
+}
+
+// Serves the block by hash without making it the tip, e.g. to build a competing branch.
+func (m *Miner) AddBranchBlock(block *protocol.Block, txs ...protocol.Transaction) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.blocks[block.Hash] = block
+	for _, tx := range txs {
+		m.txs[tx.Hash()] = tx
+	}
+}
+
+func (m *Miner) SetAccount(acc *protocol.Account) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.accounts[protocol.SerializeHashContent(acc.Address)] = acc
+}
+
+// Sets the addresses returned for NEIGHBOR_REQ.
+func (m *Miner) SetNeighbors(ipports ...string) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.neighbors = ipports
+}
+
+// Replaces the default answer to a message type. A nil handler restores the default answer.
+func (m *Miner) Handle(typeID uint8, handler HandlerFunc) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if handler == nil {
+		delete(m.handlers, typeID)
+		return
+	}
+
+	m.handlers[typeID] = handler
+}
+
+// Returns the txs broadcasted to the miner and accepted, in the order they were received.
+func (m *Miner) ReceivedTxs() []protocol.Transaction {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	return append([]protocol.Transaction{}, m.received...)
+}
+
+// Appends the block to the chain and pushes its header to every connected client.
+func (m *Miner) BroadcastBlockHeader(block *protocol.Block, txs ...protocol.Transaction) {
+	m.AddBlock(block, txs...)
+
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	packet := p2p.BuildPacket(p2p.BLOCK_HEADER_BRDCST, block.EncodeHeader())
+	for conn := range m.conns {
+		conn.Write(packet)
+	}
+}
+
+func (m *Miner) serve() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go m.handleConn(conn)
+	}
+}
+
+// Clients completing the handshake keep their connection. Other connections are one-shot tx broadcasts.
+func (m *Miner) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	header, payload, err := p2p.RcvData_(conn)
+	if err != nil {
+		return
+	}
+
+	if header.TypeID != p2p.CLIENT_PING {
+		conn.Write(m.answer(header.TypeID, payload))
+		return
+	}
+
+	m.l.Lock()
+	m.conns[conn] = true
+	m.l.Unlock()
+
+	defer func() {
+		m.l.Lock()
+		delete(m.conns, conn)
+		m.l.Unlock()
+	}()
+
+	conn.Write(p2p.BuildPacket(p2p.CLIENT_PONG, nil))
+
+	for {
+		header, payload, err := p2p.RcvData_(conn)
+		if err != nil {
+			return
+		}
+
+		if packet := m.answer(header.TypeID, payload); packet != nil {
+			m.l.Lock()
+			conn.Write(packet)
+			m.l.Unlock()
+		}
+	}
+}
+
+func (m *Miner) answer(typeID uint8, payload []byte) []byte {
+	m.l.Lock()
+	handler, scripted := m.handlers[typeID]
+	m.l.Unlock()
+
+	if !scripted {
+		handler = m.defaultHandler(typeID)
+	}
+
+	resType, resPayload := handler(payload)
+	if resType == 0 {
+		return nil
+	}
+
+	return p2p.BuildPacket(resType, resPayload)
+}
+
+func (m *Miner) defaultHandler(typeID uint8) HandlerFunc {
+	switch typeID {
+	case p2p.BLOCK_REQ:
+		return m.blockReq
+	case p2p.BLOCK_HEADER_REQ:
+		return m.blockHeaderReq
+	case p2p.FUNDSTX_REQ, p2p.ACCTX_REQ, p2p.CONFIGTX_REQ, p2p.STAKETX_REQ:
+		return func(payload []byte) (uint8, []byte) {
+			return m.txReq(typeID, payload)
+		}
+	case p2p.ACC_REQ:
+		return func(payload []byte) (uint8, []byte) {
+			return m.accReq(p2p.ACC_RES, payload)
+		}
+	case p2p.ROOTACC_REQ:
+		return func(payload []byte) (uint8, []byte) {
+			return m.accReq(p2p.ROOTACC_RES, payload)
+		}
+	case p2p.INTERMEDIATE_NODES_REQ:
+		return m.intermediateNodesReq
+	case p2p.NEIGHBOR_REQ:
+		return m.neighborReq
+	case p2p.FUNDSTX_BRDCST, p2p.ACCTX_BRDCST, p2p.CONFIGTX_BRDCST, p2p.STAKETX_BRDCST, p2p.UPDATETX_BRDCST:
+		return func(payload []byte) (uint8, []byte) {
+			return m.txBrdcst(typeID, payload)
+		}
+	}
+
+	return func(payload []byte) (uint8, []byte) {
+		return p2p.NOT_FOUND, []byte(fmt.Sprintf("unsupported message type %v", typeID))
+	}
+}
+
+func (m *Miner) blockReq(payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	block := m.blocks[toHash(payload)]
+	if block == nil {
+		return p2p.NOT_FOUND, []byte("block not found")
+	}
+
+	return p2p.BLOCK_RES, block.Encode()
+}
+
+// An empty payload requests the latest header.
+func (m *Miner) blockHeaderReq(payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	var block *protocol.Block
+	if len(payload) == 0 {
+		if len(m.chain) > 0 {
+			block = m.chain[len(m.chain)-1]
+		}
+	} else {
+		block = m.blocks[toHash(payload)]
+	}
+
+	if block == nil {
+		return p2p.NOT_FOUND, []byte("block header not found")
+	}
+
+	return p2p.BlOCK_HEADER_RES, block.EncodeHeader()
+}
+
+var txResponses = map[uint8]uint8{
+	p2p.FUNDSTX_REQ:  p2p.FUNDSTX_RES,
+	p2p.ACCTX_REQ:    p2p.ACCTX_RES,
+	p2p.CONFIGTX_REQ: p2p.CONFIGTX_RES,
+	p2p.STAKETX_REQ:  p2p.STAKETX_RES,
+}
+
+func (m *Miner) txReq(typeID uint8, payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	tx := m.txs[toHash(payload)]
+	if tx == nil {
+		return p2p.NOT_FOUND, []byte("tx not found")
+	}
+
+	return txResponses[typeID], tx.Encode()
+}
+
+// Unknown addresses are answered with an empty account, like the miner does.
+func (m *Miner) accReq(resType uint8, payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	acc := m.accounts[toHash(payload)]
+	if acc == nil {
+		acc = new(protocol.Account)
+	}
+
+	return resType, acc.Encode()
+}
+
+func (m *Miner) intermediateNodesReq(payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	hashes := protocol.Decode(payload, 32)
+	if len(hashes) != 2 {
+		return p2p.NOT_FOUND, []byte("invalid intermediate nodes request")
+	}
+
+	block := m.blocks[toHash(hashes[0])]
+	if block == nil {
+		return p2p.NOT_FOUND, []byte("block not found")
+	}
+
+	tree := protocol.BuildMerkleTree(block)
+	if tree == nil {
+		return p2p.NOT_FOUND, []byte("block has no txs")
+	}
+
+	leaf := protocol.GetLeaf(tree, toHash(hashes[1]))
+	if leaf == nil {
+		return p2p.NOT_FOUND, []byte("tx not in block")
+	}
+
+	nodes, err := protocol.GetIntermediate(leaf)
+	if err != nil {
+		return p2p.NOT_FOUND, []byte(err.Error())
+	}
+
+	var data [][]byte
+	for _, node := range nodes {
+		data = append(data, node.Hash[:])
+	}
+
+	return p2p.INTERMEDIATE_NODES_RES, protocol.Encode(data, 32)
+}
+
+// Answers like the miner does, neighbors without an ipv4 address are not listed.
+func (m *Miner) neighborReq(payload []byte) (uint8, []byte) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	var resPayload []byte
+	for _, ipport := range m.neighbors {
+		entry, err := encodeNeighbor(ipport)
+		if err != nil {
+			continue
+		}
+
+		resPayload = append(resPayload, entry...)
+	}
+
+	return p2p.NEIGHBOR_RES, resPayload
+}
+
+func (m *Miner) txBrdcst(typeID uint8, payload []byte) (uint8, []byte) {
+	tx, err := decodeTx(typeID, payload)
+	if err != nil {
+		return p2p.NOT_FOUND, []byte(err.Error())
+	}
+
+	if m.AcceptTx != nil {
+		if err := m.AcceptTx(tx); err != nil {
+			return p2p.NOT_FOUND, []byte(err.Error())
+		}
+	}
+
+	m.l.Lock()
+	m.received = append(m.received, tx)
+	m.txs[tx.Hash()] = tx
+	m.l.Unlock()
+
+	return p2p.TX_BRDCST_ACK, nil
+}
+
+func decodeTx(typeID uint8, payload []byte) (tx protocol.Transaction, err error) {
+	switch typeID {
+	case p2p.FUNDSTX_BRDCST:
+		var fundsTx *protocol.FundsTx
+		if fundsTx = fundsTx.Decode(payload); fundsTx != nil {
+			return fundsTx, nil
+		}
+	case p2p.ACCTX_BRDCST:
+		var accTx *protocol.AccTx
+		if accTx = accTx.Decode(payload); accTx != nil {
+			return accTx, nil
+		}
+	case p2p.CONFIGTX_BRDCST:
+		var configTx *protocol.ConfigTx
+		if configTx = configTx.Decode(payload); configTx != nil {
+			return configTx, nil
+		}
+	case p2p.STAKETX_BRDCST:
+		var stakeTx *protocol.StakeTx
+		if stakeTx = stakeTx.Decode(payload); stakeTx != nil {
+			return stakeTx, nil
+		}
+	case p2p.UPDATETX_BRDCST:
+		var updateTx *protocol.UpdateTx
+		if updateTx = updateTx.Decode(payload); updateTx != nil {
+			return updateTx, nil
+		}
+	}
+
+	return nil, errors.New("tx could not be decoded")
+}
+
+// Encodes a NEIGHBOR_RES entry: the 4 byte ipv4 address followed by the 2 byte port.
+func encodeNeighbor(ipport string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(ipport)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, errors.New(fmt.Sprintf("%v is not an ipv4 address", host))
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := append([]byte{}, ip...)
+	entry = binary.BigEndian.AppendUint16(entry, uint16(port))
+
+	return entry, nil
+}
+
+func toHash(data []byte) (hash [32]byte) {
+	copy(hash[:], data)
+
+	return hash
+}
@@ -0,0 +1,108 @@
+package minertest
+
+import (
+	"bytes"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+// Sends a request over conn and returns the answer.
+func exchange(t *testing.T, conn net.Conn, typeID uint8, payload []byte) (*p2p.Header, []byte) {
+	t.Helper()
+
+	if _, err := conn.Write(p2p.BuildPacket(typeID, payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	header, resPayload, err := p2p.RcvData_(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return header, resPayload
+}
+
+func TestNeighborReq(t *testing.T) {
+	m, err := NewMiner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conn, err := net.Dial("tcp", m.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	handshake, _ := p2p.PrepareHandshake(p2p.CLIENT_PING, 8000)
+	if header, _ := exchange(t, conn, p2p.CLIENT_PING, handshake[p2p.HEADER_LEN:]); header.TypeID != p2p.CLIENT_PONG {
+		t.Fatalf("Handshake answered with %v, want CLIENT_PONG", p2p.LogMapping[header.TypeID])
+	}
+
+	//Only ipv4 neighbors fit the 6 byte entries of the miner.
+	m.SetNeighbors("10.0.0.1:8080", "[2001:db8::1]:8000", "miner.example.org:8000", "192.168.1.20:80")
+
+	header, payload := exchange(t, conn, p2p.NEIGHBOR_REQ, nil)
+	if header.TypeID != p2p.NEIGHBOR_RES {
+		t.Fatalf("NEIGHBOR_REQ answered with %v, want NEIGHBOR_RES", p2p.LogMapping[header.TypeID])
+	}
+
+	if want := []byte{10, 0, 0, 1, 0x1f, 0x90, 192, 168, 1, 20, 0x00, 0x50}; !bytes.Equal(payload, want) {
+		t.Errorf("NEIGHBOR_RES payload = %v, want %v", payload, want)
+	}
+}
+
+// Headers carry the EncodeHeader fields only, like the miner sends them, the full block is needed to recompute the hash.
+func TestBlockHeaderReqSendsHeaderFieldsOnly(t *testing.T) {
+	m, err := NewMiner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	block := &protocol.Block{
+		Height:          1,
+		Timestamp:       42,
+		MerkleRoot:      [32]byte{'m'},
+		CommitmentProof: [256]byte{'p'},
+	}
+	block.Hash = block.HashBlock()
+	m.AddBlock(block)
+
+	request := func(typeID uint8, payload []byte) (*p2p.Header, []byte) {
+		conn, err := net.Dial("tcp", m.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		return exchange(t, conn, typeID, payload)
+	}
+
+	_, payload := request(p2p.BLOCK_HEADER_REQ, block.Hash[:])
+	header := new(protocol.Block).Decode(payload)
+	if header.Hash != block.Hash || header.Height != block.Height {
+		t.Fatalf("Header = %x at height %v, want %x at height %v", header.Hash, header.Height, block.Hash, block.Height)
+	}
+
+	if header.Timestamp != 0 || header.MerkleRoot != [32]byte{} || header.CommitmentProof != [256]byte{} {
+		t.Error("Header carries fields outside of EncodeHeader")
+	}
+
+	if header.HashBlock() == header.Hash {
+		t.Error("Header hash recomputed without the full block")
+	}
+
+	_, payload = request(p2p.BLOCK_REQ, block.Hash[:])
+	if full := new(protocol.Block).Decode(payload); full.HashBlock() != block.Hash || full.MerkleRoot != block.MerkleRoot {
+		t.Error("Block does not recompute to its hash")
+	}
+}
@@ -1,9 +1,7 @@
 package network
 
 import (
-	"math/rand"
 	"net"
-	"strings"
 	"sync"
 )
 
@@ -15,6 +13,8 @@ type peer struct {
 	ch           chan []byte
 	l            sync.Mutex
 	listenerPort string
+	dial         string
+	stats        peerStats
 }
 
 //Block constructor, argument is the previous block in the blockchain.
@@ -28,6 +28,14 @@ func newPeer(conn net.Conn, listenerPort string) *peer {
 	return p
 }
 
+// Peers that sent invalid data. They are disconnected and not dialed again.
+type flaggedStruct struct {
+	ipports map[string]error
+	l       sync.Mutex
+}
+
+var flagged = flaggedStruct{ipports: make(map[string]error)}
+
 //PeerStruct is a thread-safe map that supports all necessary map operations needed by the server.
 type peersStruct struct {
 	minerConns map[*peer]bool
@@ -35,45 +43,63 @@ type peersStruct struct {
 }
 
 func (p *peer) getIPPort() string {
-	ip := strings.Split(p.conn.RemoteAddr().String(), ":")
-	//Cut off original port.
-	port := p.listenerPort
+	host, _, err := net.SplitHostPort(p.conn.RemoteAddr().String())
+	if err != nil {
+		host = p.conn.RemoteAddr().String()
+	}
 
-	return ip[0] + ":" + port
+	//Replace the original port with the listener port.
+	return net.JoinHostPort(host, p.listenerPort)
 }
 
-func (peers peersStruct) add(p *peer) {
+func (peers *peersStruct) add(p *peer) {
 	peers.peerMutex.Lock()
 	defer peers.peerMutex.Unlock()
 
 	peers.minerConns[p] = true
 }
 
-func (peers peersStruct) delete(p *peer) {
+func (peers *peersStruct) delete(p *peer) {
 	peers.peerMutex.Lock()
 	defer peers.peerMutex.Unlock()
 
 	delete(peers.minerConns, p)
 }
 
-func (peers peersStruct) len(peerType uint) (length int) {
+func (peers *peersStruct) len(peerType uint) (length int) {
+	peers.peerMutex.Lock()
+	defer peers.peerMutex.Unlock()
+
 	length = len(peers.minerConns)
 
 	return length
 }
 
-func (peers peersStruct) getRandomPeer() (p *peer) {
+// Picks a peer at random, weighted by its health.
+func (peers *peersStruct) getRandomPeer() (p *peer) {
 	//Acquire list before locking, otherwise deadlock
-	peerList := peers.getAllPeers()
+	return pickByHealth(peers.getAllPeers())
+}
+
+// Picks up to n distinct peers at random, weighted by their health.
+func (peers *peersStruct) getRandomPeers(n int) (peerList []*peer) {
+	remaining := peers.getAllPeers()
+	for len(peerList) < n && len(remaining) > 0 {
+		p := pickByHealth(remaining)
+		peerList = append(peerList, p)
 
-	if len(peerList) == 0 {
-		return nil
-	} else {
-		return peerList[int(rand.Uint32())%len(peerList)]
+		for i := range remaining {
+			if remaining[i] == p {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
 	}
+
+	return peerList
 }
 
-func (peers peersStruct) getAllPeers() []*peer {
+func (peers *peersStruct) getAllPeers() []*peer {
 	peers.peerMutex.Lock()
 	defer peers.peerMutex.Unlock()
 
@@ -86,12 +112,38 @@ func (peers peersStruct) getAllPeers() []*peer {
 	return peerList
 }
 
-func (peers peersStruct) contains(ipport string) bool {
+func (peers *peersStruct) contains(ipport string) bool {
+	peers.peerMutex.Lock()
+	defer peers.peerMutex.Unlock()
+
 	for peer, _ := range peers.minerConns {
-		if peer.getIPPort() == ipport {
+		if peer.getIPPort() == ipport || peer.dial == ipport {
 			return true
 		}
 	}
 
 	return false
 }
+
+// Flags a peer that sent invalid data. The connection to the peer is closed and the peer is not dialed again.
+func FlagPeer(ipport string, reason error) {
+	logger.Printf("Flagging peer %v: %v\n", ipport, reason)
+
+	flagged.l.Lock()
+	flagged.ipports[ipport] = reason
+	flagged.l.Unlock()
+
+	for _, p := range peers.getAllPeers() {
+		if p.getIPPort() == ipport {
+			p.conn.Close()
+		}
+	}
+}
+
+func isFlagged(ipport string) bool {
+	flagged.l.Lock()
+	defer flagged.l.Unlock()
+
+	_, ok := flagged.ipports[ipport]
+	return ok
+}
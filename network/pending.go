@@ -0,0 +1,181 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"sync"
+	"time"
+)
+
+// The result of a single request. A response is only accepted from the peer the request was sent to and with the
+// expected type. If the response carries the requested hash, it must match as well. Responses without a hash are
+// matched to the oldest pending request, miners answer the requests of a connection in order.
+type Future struct {
+	p       *peer
+	resType uint8
+	key     [32]byte
+	keyed   bool
+	sent    time.Time
+	result  chan response
+
+	//Futures of the requests sent to the quorum, set instead of p and result.
+	members []*Future
+
+	//Number of members that have to agree, util.Config.QuorumThreshold if 0.
+	threshold int
+}
+
+type response struct {
+	payload interface{}
+	err     error
+}
+
+// Requests waiting for their response, in the order they were sent.
+type pendingStruct struct {
+	requests []*Future
+	l        sync.Mutex
+}
+
+var pending = pendingStruct{}
+
+// Waits for the response until util.FETCH_TIMEOUT runs out. A response arriving later is dropped.
+func (f *Future) Get() (payload interface{}, err error) {
+	deadline := time.Now().Add(util.FETCH_TIMEOUT * time.Second)
+	if f.members != nil {
+		return f.getQuorum(deadline)
+	}
+
+	return f.getUntil(deadline)
+}
+
+// The address of the peer the request was sent to, empty for requests sent to the quorum.
+func (f *Future) Peer() string {
+	if f.p == nil {
+		return ""
+	}
+
+	return f.p.getIPPort()
+}
+
+func (f *Future) getUntil(deadline time.Time) (payload interface{}, err error) {
+	select {
+	case res := <-f.result:
+		return res.payload, res.err
+	case <-time.After(time.Until(deadline)):
+		pending.remove(f)
+		f.p.failed()
+		return nil, errors.New("Fetching timed out.")
+	}
+}
+
+func (pending *pendingStruct) add(f *Future) {
+	pending.l.Lock()
+	defer pending.l.Unlock()
+
+	pending.requests = append(pending.requests, f)
+}
+
+func (pending *pendingStruct) remove(f *Future) {
+	pending.l.Lock()
+	defer pending.l.Unlock()
+
+	for i, request := range pending.requests {
+		if request == f {
+			pending.requests = append(pending.requests[:i], pending.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+// Hands the response to the pending request it answers. A response carrying a hash goes to the oldest request for
+// that hash, requests without a key only take it if no request waits for the hash. Returns false if no request
+// matches, the response is dropped in this case. Never blocks, every future buffers its single result.
+func (pending *pendingStruct) resolve(p *peer, resType uint8, key [32]byte, keyed bool, payload interface{}) bool {
+	pending.l.Lock()
+	defer pending.l.Unlock()
+
+	match := -1
+	for i, request := range pending.requests {
+		if request.p != p || request.resType != resType {
+			continue
+		}
+
+		if request.keyed && keyed {
+			if request.key == key {
+				match = i
+				break
+			}
+
+			continue
+		}
+
+		if match == -1 {
+			match = i
+		}
+
+		//A response without a hash answers the oldest request.
+		if !keyed {
+			break
+		}
+	}
+
+	if match == -1 {
+		return false
+	}
+
+	request := pending.requests[match]
+	pending.requests = append(pending.requests[:match], pending.requests[match+1:]...)
+	request.result <- response{payload: payload}
+	p.succeeded(time.Since(request.sent))
+
+	return true
+}
+
+// Fails the pending requests sent to a peer that disconnected, so that their callers do not wait for the timeout.
+func (pending *pendingStruct) cancel(p *peer) {
+	pending.l.Lock()
+	defer pending.l.Unlock()
+
+	var remaining []*Future
+	for _, request := range pending.requests {
+		if request.p != p {
+			remaining = append(remaining, request)
+			continue
+		}
+
+		request.result <- response{err: errors.New(fmt.Sprintf("Connection to %v closed before the response arrived.", p.getIPPort()))}
+	}
+
+	pending.requests = remaining
+}
+
+// Sends a request to a random peer and registers the future its response is delivered to. The first 32 bytes of the
+// key are the requested hash the response has to carry, requests without a key are answered by any response of the
+// expected type. Header requests append the hash without tx to the block hash, responses are matched by the latter.
+func request(reqType uint8, resType uint8, key []byte, payload []byte) (*Future, error) {
+	p := peers.getRandomPeer()
+	if p == nil {
+		return nil, errors.New("Couldn't get a connection, request not transmitted.")
+	}
+
+	return requestPeer(p, reqType, resType, key, payload), nil
+}
+
+func requestPeer(p *peer, reqType uint8, resType uint8, key []byte, payload []byte) *Future {
+	f := &Future{
+		p:       p,
+		resType: resType,
+		keyed:   len(key) >= 32,
+		sent:    time.Now(),
+		result:  make(chan response, 1),
+	}
+	copy(f.key[:], key)
+
+	//Register before sending, the response may arrive before sendData returns.
+	pending.add(f)
+	sendData(p, p2p.BuildPacket(reqType, payload))
+
+	return f
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+// Registers a future for a request sent to p without sending anything.
+func pendingFuture(pending *pendingStruct, p *peer, resType uint8, key []byte) *Future {
+	f := &Future{
+		p:       p,
+		resType: resType,
+		keyed:   len(key) >= 32,
+		sent:    time.Now(),
+		result:  make(chan response, 1),
+	}
+	copy(f.key[:], key)
+	pending.add(f)
+
+	return f
+}
+
+// Returns the payload delivered to f, or nil if f is still waiting.
+func delivered(f *Future) interface{} {
+	select {
+	case res := <-f.result:
+		return res.payload
+	default:
+		return nil
+	}
+}
+
+// A 32 byte key starting with b.
+func key(b byte) []byte {
+	k := make([]byte, 32)
+	k[0] = b
+
+	return k
+}
+
+func hash(b byte) (h [32]byte) {
+	copy(h[:], key(b))
+	return h
+}
+
+func TestResolveHeaderQueryIsKeyed(t *testing.T) {
+	pending := &pendingStruct{}
+	p := newPeer(nil, "")
+
+	//Header requests carry the block hash followed by the hash without tx.
+	query := append(key(1), make([]byte, 32)...)
+	f1 := pendingFuture(pending, p, 1, query)
+	f2 := pendingFuture(pending, p, 1, append(key(2), make([]byte, 32)...))
+
+	if !f1.keyed {
+		t.Fatalf("Header request with a %v byte query is not keyed", len(query))
+	}
+
+	if !pending.resolve(p, 1, hash(2), true, "second") {
+		t.Fatal("Response for the second header was dropped")
+	}
+
+	if got := delivered(f1); got != nil {
+		t.Errorf("Request for the first header took %v", got)
+	}
+
+	if got := delivered(f2); got != "second" {
+		t.Errorf("Request for the second header got %v, want second", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p, other := newPeer(nil, ""), newPeer(nil, "")
+
+	tests := []struct {
+		name    string
+		keys    [][]byte //Keys of the pending requests, oldest first
+		key     [32]byte //Hash of the response
+		keyed   bool
+		want    int //Index of the request that takes the response, -1 if it is dropped
+		otherP  bool
+		resType uint8
+	}{
+		{"unkeyed response answers oldest", [][]byte{nil, key(1)}, [32]byte{}, false, 0, false, 1},
+		{"unkeyed response answers keyed request", [][]byte{key(1), nil}, [32]byte{}, false, 0, false, 1},
+		{"keyed response answers matching request", [][]byte{key(1), key(2)}, hash(2), true, 1, false, 1},
+		{"keyed response prefers matching over unkeyed", [][]byte{nil, key(2)}, hash(2), true, 1, false, 1},
+		{"keyed response falls back to unkeyed", [][]byte{key(1), nil}, hash(2), true, 1, false, 1},
+		{"keyed response without match dropped", [][]byte{key(1)}, hash(2), true, -1, false, 1},
+		{"other type dropped", [][]byte{nil}, [32]byte{}, false, -1, false, 2},
+		{"other peer dropped", [][]byte{nil}, [32]byte{}, false, -1, true, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pending := &pendingStruct{}
+
+			var futures []*Future
+			for _, key := range test.keys {
+				futures = append(futures, pendingFuture(pending, p, 1, key))
+			}
+
+			from := p
+			if test.otherP {
+				from = other
+			}
+
+			if resolved := pending.resolve(from, test.resType, test.key, test.keyed, "res"); resolved != (test.want != -1) {
+				t.Fatalf("resolve() = %v, want %v", resolved, test.want != -1)
+			}
+
+			for i, f := range futures {
+				got := delivered(f)
+				if i == test.want && got != "res" {
+					t.Errorf("Request %v got %v, want the response", i, got)
+				}
+
+				if i != test.want && got != nil {
+					t.Errorf("Request %v took the response", i)
+				}
+			}
+
+			if want := len(test.keys); test.want != -1 && len(pending.requests) != want-1 {
+				t.Errorf("%v requests pending, want %v", len(pending.requests), want-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"github.com/way365/bazo-client/util"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A miner address the client may connect to, learned from the configuration or from neighbor responses.
+type candidate struct {
+	failures int
+	retryAt  time.Time
+}
+
+// Thread-safe set of candidate addresses with their reconnection backoff.
+type candidatesStruct struct {
+	ipports map[string]*candidate
+	l       sync.Mutex
+}
+
+var candidates = candidatesStruct{ipports: make(map[string]*candidate)}
+
+func (candidates *candidatesStruct) add(ipport string) {
+	candidates.l.Lock()
+	defer candidates.l.Unlock()
+
+	if ipport == util.Config.ThisIpport {
+		return
+	}
+
+	if _, exists := candidates.ipports[ipport]; !exists {
+		candidates.ipports[ipport] = new(candidate)
+	}
+}
+
+// Returns the addresses whose backoff ran out and which are neither connected nor flagged. The peers are checked
+// after the candidates are released, so that the candidates and the peers are never locked together.
+func (candidates *candidatesStruct) dialable() (ipports []string) {
+	candidates.l.Lock()
+	now := time.Now()
+	var due []string
+	for ipport, c := range candidates.ipports {
+		if !now.Before(c.retryAt) {
+			due = append(due, ipport)
+		}
+	}
+	candidates.l.Unlock()
+
+	for _, ipport := range due {
+		if !peers.contains(ipport) && !isFlagged(ipport) {
+			ipports = append(ipports, ipport)
+		}
+	}
+
+	return ipports
+}
+
+func (candidates *candidatesStruct) connected(ipport string) {
+	candidates.l.Lock()
+	defer candidates.l.Unlock()
+
+	if c, exists := candidates.ipports[ipport]; exists {
+		c.failures = 0
+		c.retryAt = time.Time{}
+	}
+}
+
+// Backs the address off exponentially, starting at util.RECONNECT_BACKOFF_MIN up to util.RECONNECT_BACKOFF_MAX.
+func (candidates *candidatesStruct) failed(ipport string) {
+	candidates.l.Lock()
+	defer candidates.l.Unlock()
+
+	c, exists := candidates.ipports[ipport]
+	if !exists {
+		return
+	}
+
+	backoff := time.Duration(util.RECONNECT_BACKOFF_MAX) * time.Second
+	if c.failures < 16 {
+		backoff = time.Duration(util.RECONNECT_BACKOFF_MIN<<uint(c.failures)) * time.Second
+		if backoff > util.RECONNECT_BACKOFF_MAX*time.Second {
+			backoff = util.RECONNECT_BACKOFF_MAX * time.Second
+		}
+	}
+
+	c.failures++
+	c.retryAt = time.Now().Add(backoff)
+
+	logger.Printf("Retrying %v in %v\n", ipport, backoff)
+}
+
+// Request statistics of a connected miner, used to prefer healthy peers.
+type peerStats struct {
+	latency  time.Duration
+	failures int
+	l        sync.Mutex
+}
+
+// Records a response. The latency is a moving average over the recent responses.
+func (p *peer) succeeded(latency time.Duration) {
+	p.stats.l.Lock()
+	defer p.stats.l.Unlock()
+
+	if p.stats.latency == 0 {
+		p.stats.latency = latency
+	} else {
+		p.stats.latency = (3*p.stats.latency + latency) / 4
+	}
+
+	p.stats.failures = 0
+}
+
+// Records a timed out request. A miner that does not answer util.MAX_PEER_FAILURES requests in a row is disconnected,
+// the health service reconnects after the backoff.
+func (p *peer) failed() {
+	p.stats.l.Lock()
+	p.stats.failures++
+	failures := p.stats.failures
+	p.stats.l.Unlock()
+
+	if failures >= util.MAX_PEER_FAILURES {
+		logger.Printf("Disconnecting %v after %v timed out requests\n", p.getIPPort(), failures)
+		p.conn.Close()
+	}
+}
+
+// Peers answering fast and reliably get a higher weight when picking a peer for a request.
+func (p *peer) weight() float64 {
+	p.stats.l.Lock()
+	defer p.stats.l.Unlock()
+
+	return 1 / (float64(1+p.stats.failures) * (1 + p.stats.latency.Seconds()*10))
+}
+
+// Picks a peer with a probability proportional to its weight.
+func pickByHealth(peerList []*peer) *peer {
+	if len(peerList) == 0 {
+		return nil
+	}
+
+	weights := make([]float64, len(peerList))
+	total := 0.0
+	for i, p := range peerList {
+		weights[i] = p.weight()
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, weight := range weights {
+		if r < weight {
+			return peerList[i]
+		}
+
+		r -= weight
+	}
+
+	return peerList[len(peerList)-1]
+}
@@ -0,0 +1,153 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"sync"
+	"time"
+)
+
+// Number of disagreements with the quorum per miner address.
+type disagreementsStruct struct {
+	ipports map[string]int
+	l       sync.Mutex
+}
+
+var disagreements = disagreementsStruct{ipports: make(map[string]int)}
+
+// Sends the request to util.Config.QuorumPeers distinct peers if the quorum mode is enabled, to a single peer
+// otherwise. The future of a quorum request only returns a result agreed on by util.Config.QuorumThreshold peers.
+func quorumRequest(reqType uint8, resType uint8, key []byte, payload []byte) (*Future, error) {
+	if util.Config.QuorumPeers <= 1 {
+		return request(reqType, resType, key, payload)
+	}
+
+	peerList := peers.getRandomPeers(util.Config.QuorumPeers)
+	if len(peerList) < util.Config.QuorumPeers {
+		return nil, errors.New(fmt.Sprintf("Quorum of %v peers required, %v connected.", util.Config.QuorumPeers, len(peerList)))
+	}
+
+	f := &Future{resType: resType}
+	for _, p := range peerList {
+		f.members = append(f.members, requestPeer(p, reqType, resType, key, payload))
+	}
+
+	return f, nil
+}
+
+// Sends the request to the quorum if the quorum mode is enabled, to all connected peers otherwise. Without the quorum
+// mode, the future only returns a result all of them agree on. Used for results the client keeps trusting once they
+// are fetched.
+func unanimousRequest(reqType uint8, resType uint8, key []byte, payload []byte) (*Future, error) {
+	if util.Config.QuorumPeers > 1 {
+		return quorumRequest(reqType, resType, key, payload)
+	}
+
+	peerList := peers.getAllPeers()
+	if len(peerList) == 0 {
+		return nil, errors.New("Couldn't get a connection, request not transmitted.")
+	}
+
+	f := &Future{resType: resType, threshold: len(peerList)}
+	for _, p := range peerList {
+		f.members = append(f.members, requestPeer(p, reqType, resType, key, payload))
+	}
+
+	return f, nil
+}
+
+// Waits for the responses of all members until the deadline and returns the result of the largest group of
+// identical responses. Members that time out or send a response that cannot be decoded fail, they count as neither
+// agreeing nor disagreeing.
+func (f *Future) getQuorum(deadline time.Time) (payload interface{}, err error) {
+	groups := make(map[string][]*Future)
+	results := make(map[string]interface{})
+
+	var largest string
+	failed := 0
+	for _, member := range f.members {
+		memberPayload, err := member.getUntil(deadline)
+		if err != nil {
+			logger.Printf("Quorum member %v did not answer: %v\n", member.p.getIPPort(), err)
+			failed++
+			continue
+		}
+
+		fingerprint, ok := fingerprint(memberPayload)
+		if !ok {
+			logger.Printf("Quorum member %v sent a %v that could not be decoded\n", member.p.getIPPort(), p2p.LogMapping[f.resType])
+			failed++
+			continue
+		}
+
+		groups[fingerprint] = append(groups[fingerprint], member)
+		results[fingerprint] = memberPayload
+
+		if len(groups[fingerprint]) > len(groups[largest]) {
+			largest = fingerprint
+		}
+	}
+
+	for fingerprint, members := range groups {
+		if fingerprint == largest {
+			continue
+		}
+
+		for _, member := range members {
+			disagreements.record(member.p.getIPPort(), f.resType)
+		}
+	}
+
+	threshold := util.Config.QuorumThreshold
+	if f.threshold > 0 {
+		threshold = f.threshold
+	}
+
+	if agreed := len(groups[largest]); agreed < threshold {
+		return nil, errors.New(fmt.Sprintf("Quorum not reached: %v of %v peers agree, %v failed, %v required.", agreed, len(f.members), failed, threshold))
+	}
+
+	return results[largest], nil
+}
+
+// Identical responses have identical fingerprints. The peer a header was received from is not part of it. Returns
+// false for a response that could not be decoded. An unknown account is a valid response, the miners answer with an
+// empty account.
+func fingerprint(payload interface{}) (string, bool) {
+	switch result := payload.(type) {
+	case *protocol.Account:
+		if result != nil {
+			return string(result.Encode()), true
+		}
+	case *ReceivedBlockHeader:
+		if result != nil && result.Header != nil {
+			return string(result.Header.EncodeHeader()), true
+		}
+	}
+
+	return "", false
+}
+
+func (disagreements *disagreementsStruct) record(ipport string, resType uint8) {
+	disagreements.l.Lock()
+	defer disagreements.l.Unlock()
+
+	disagreements.ipports[ipport]++
+	logger.Printf("Peer %v disagreed with the quorum on %v (%v disagreements)\n", ipport, p2p.LogMapping[resType], disagreements.ipports[ipport])
+}
+
+// Returns the number of disagreements with the quorum per miner address.
+func Disagreements() map[string]int {
+	disagreements.l.Lock()
+	defer disagreements.l.Unlock()
+
+	result := make(map[string]int)
+	for ipport, count := range disagreements.ipports {
+		result[ipport] = count
+	}
+
+	return result
+}
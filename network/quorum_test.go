@@ -0,0 +1,119 @@
+package network
+
+import (
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+// A member that answers with payload, or never answers if payload is nil.
+type quorumMember struct {
+	payload interface{}
+}
+
+func TestGetQuorum(t *testing.T) {
+	known := &protocol.Account{Address: [64]byte{1}, Balance: 10}
+	other := &protocol.Account{Address: [64]byte{1}, Balance: 20}
+	unknown := &protocol.Account{}
+	var undecodable *protocol.Account
+
+	tests := []struct {
+		name      string
+		members   []quorumMember
+		threshold int
+		want      *protocol.Account //nil if the quorum is not reached
+	}{
+		{"all agree", []quorumMember{{known}, {known}, {known}}, 2, known},
+		{"majority agrees", []quorumMember{{known}, {other}, {known}}, 2, known},
+		{"unknown account agreed on", []quorumMember{{unknown}, {unknown}, {known}}, 2, unknown},
+		{"undecodable responses do not agree", []quorumMember{{undecodable}, {undecodable}, {known}}, 2, nil},
+		{"undecodable response does not count", []quorumMember{{undecodable}, {known}, {known}}, 2, known},
+		{"timed out members do not count", []quorumMember{{nil}, {nil}, {known}}, 2, nil},
+		{"no agreement", []quorumMember{{known}, {other}, {unknown}}, 2, nil},
+	}
+
+	threshold := util.Config.QuorumThreshold
+	defer func() { util.Config.QuorumThreshold = threshold }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			util.Config.QuorumThreshold = test.threshold
+
+			f := &Future{resType: p2p.ACC_RES}
+			for _, member := range test.members {
+				conn, _ := net.Pipe()
+				defer conn.Close()
+
+				memberFuture := &Future{p: newPeer(conn, "8000"), resType: p2p.ACC_RES, result: make(chan response, 1)}
+				if member.payload != nil {
+					memberFuture.result <- response{payload: member.payload}
+				}
+
+				f.members = append(f.members, memberFuture)
+			}
+
+			payload, err := f.getQuorum(time.Now().Add(10 * time.Millisecond))
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("getQuorum() = %v, want an error", payload)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("getQuorum() failed: %v", err)
+			}
+
+			if got := payload.(*protocol.Account); got.Balance != test.want.Balance || got.Address != test.want.Address {
+				t.Errorf("getQuorum() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetQuorumSharesDeadline(t *testing.T) {
+	f := &Future{resType: p2p.ACC_RES}
+	for i := 0; i < 3; i++ {
+		conn, _ := net.Pipe()
+		defer conn.Close()
+
+		f.members = append(f.members, &Future{p: newPeer(conn, "8000"), resType: p2p.ACC_RES, result: make(chan response, 1)})
+	}
+
+	timeout := 50 * time.Millisecond
+	start := time.Now()
+	f.getQuorum(start.Add(timeout))
+
+	//Waiting for each member in turn would take three times the timeout.
+	if elapsed := time.Since(start); elapsed > 2*timeout {
+		t.Errorf("Quorum of silent members returned after %v, want about %v", elapsed, timeout)
+	}
+}
+
+func TestGetQuorumUnanimous(t *testing.T) {
+	known := &protocol.Account{Address: [64]byte{1}, Balance: 10}
+	other := &protocol.Account{Address: [64]byte{1}, Balance: 20}
+
+	threshold := util.Config.QuorumThreshold
+	defer func() { util.Config.QuorumThreshold = threshold }()
+	util.Config.QuorumThreshold = 1
+
+	//The threshold of the future overrides the configured one.
+	f := &Future{resType: p2p.ACC_RES, threshold: 3}
+	for _, payload := range []*protocol.Account{known, known, other} {
+		conn, _ := net.Pipe()
+		defer conn.Close()
+
+		member := &Future{p: newPeer(conn, "8000"), resType: p2p.ACC_RES, result: make(chan response, 1)}
+		member.result <- response{payload: payload}
+		f.members = append(f.members, member)
+	}
+
+	if payload, err := f.getQuorum(time.Now().Add(10 * time.Millisecond)); err == nil {
+		t.Errorf("getQuorum() = %v with a dissenting member, want an error", payload)
+	}
+}
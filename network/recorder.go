@@ -0,0 +1,111 @@
+package network
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-miner/p2p"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A message exchanged with a miner, as written to a capture file.
+type Frame struct {
+	Time     time.Time
+	Peer     string
+	Outbound bool
+	TypeID   uint8
+	Payload  []byte
+}
+
+func (frame *Frame) String() string {
+	direction := "from"
+	if frame.Outbound {
+		direction = "to"
+	}
+
+	return fmt.Sprintf("%v %v %v (%v bytes)", p2p.LogMapping[frame.TypeID], direction, frame.Peer, len(frame.Payload))
+}
+
+// Writes every frame exchanged with the miners to a capture file, as a stream of gob encoded frames. Not recording
+// while encoder is nil. The connections record concurrently, hence every access holds l.
+type recorderStruct struct {
+	file    *os.File
+	encoder *gob.Encoder
+	l       sync.Mutex
+}
+
+var recorder = &recorderStruct{}
+
+// Starts recording to the capture file at path. Must be called before Init to capture the whole session.
+func StartRecording(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Creating capture file %v failed: %v", path, err))
+	}
+
+	recorder.l.Lock()
+	defer recorder.l.Unlock()
+
+	recorder.file, recorder.encoder = file, gob.NewEncoder(file)
+
+	return nil
+}
+
+func StopRecording() {
+	recorder.l.Lock()
+	defer recorder.l.Unlock()
+
+	if recorder.file == nil {
+		return
+	}
+
+	recorder.file.Close()
+	recorder.file, recorder.encoder = nil, nil
+}
+
+func record(p *peer, outbound bool, typeID uint8, payload []byte) {
+	recorder.l.Lock()
+	defer recorder.l.Unlock()
+
+	if recorder.encoder == nil {
+		return
+	}
+
+	frame := &Frame{
+		Time:     time.Now(),
+		Peer:     p.getIPPort(),
+		Outbound: outbound,
+		TypeID:   typeID,
+		Payload:  payload,
+	}
+
+	if err := recorder.encoder.Encode(frame); err != nil {
+		logger.Printf("Recording %v failed: %v\n", frame, err)
+	}
+}
+
+// Reads all frames of a capture file.
+func ReadCapture(path string) (frames []*Frame, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	for {
+		frame := new(Frame)
+		if err := decoder.Decode(frame); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+
+			return nil, errors.New(fmt.Sprintf("Reading capture file %v failed after %v frames: %v", path, len(frames), err))
+		}
+
+		frames = append(frames, frame)
+	}
+}
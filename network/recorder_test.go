@@ -0,0 +1,52 @@
+package network
+
+import (
+	"github.com/way365/bazo-miner/p2p"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRecordWhileStopping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.bin")
+	if err := StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+	p := newPeer(conn, "8000")
+
+	//Connections keep recording while the client shuts down.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				record(p, true, p2p.CLIENT_PING, []byte{byte(j)})
+			}
+		}()
+	}
+
+	StopRecording()
+	wg.Wait()
+
+	frames, err := ReadCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frame := range frames {
+		if frame.TypeID != p2p.CLIENT_PING || !frame.Outbound || len(frame.Payload) != 1 {
+			t.Errorf("Recorded %v, want an outbound %v", frame, p2p.LogMapping[p2p.CLIENT_PING])
+		}
+	}
+
+	record(p, true, p2p.CLIENT_PING, nil)
+	if after, _ := ReadCapture(path); len(after) != len(frames) {
+		t.Errorf("Recorded %v frames after recording stopped", len(after)-len(frames))
+	}
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"net"
+	"time"
+)
+
+// Remote address of a replayed peer, as recorded in the capture.
+type replayAddr string
+
+func (addr replayAddr) Network() string { return "tcp" }
+func (addr replayAddr) String() string  { return string(addr) }
+
+// One end of a pipe that reports the recorded address of the peer as its remote address.
+type replayConn struct {
+	net.Conn
+	addr replayAddr
+}
+
+func (conn *replayConn) RemoteAddr() net.Addr {
+	return conn.addr
+}
+
+// Replays a capture against the client instead of connecting to the network. Every recorded peer is replaced by
+// a pipe. Inbound frames are fed into processIncomingMsg in the recorded order. Outbound frames are sync points:
+// the replay waits until the client sent the same message again, so that a response is only fed once its request
+// is pending. Messages the client sends differently than recorded are logged, which is where the replay diverges.
+func Replay(ctx context.Context, frames []*Frame) (diverged int) {
+	//Replay runs instead of Init.
+	logger = util.InitLogger()
+
+	replayPeers := make(map[string]*peer)
+	outbound := make(map[string]chan *Frame)
+
+	for _, frame := range frames {
+		if _, exists := replayPeers[frame.Peer]; exists {
+			continue
+		}
+
+		_, port, err := net.SplitHostPort(frame.Peer)
+		if err != nil {
+			port = ""
+		}
+
+		clientEnd, replayEnd := net.Pipe()
+		p := newPeer(&replayConn{clientEnd, replayAddr(frame.Peer)}, port)
+		p.dial = frame.Peer
+		replayPeers[frame.Peer] = p
+		outbound[frame.Peer] = make(chan *Frame, 1000)
+
+		go readReplayed(frame.Peer, replayEnd, outbound[frame.Peer])
+		peers.add(p)
+	}
+
+	for _, frame := range frames {
+		if ctx.Err() != nil {
+			break
+		}
+
+		p := replayPeers[frame.Peer]
+
+		//The replayed peers are connected without a handshake.
+		if frame.Outbound && frame.TypeID == p2p.CLIENT_PING {
+			continue
+		}
+
+		if !frame.Outbound {
+			processIncomingMsg(ctx, p, &p2p.Header{Len: uint32(len(frame.Payload)), TypeID: frame.TypeID}, frame.Payload)
+			continue
+		}
+
+		if !awaitReplayed(frame, outbound[frame.Peer]) {
+			diverged++
+		}
+	}
+
+	for ipport, p := range replayPeers {
+		peers.delete(p)
+		p.conn.Close()
+
+		for unexpected := range outbound[ipport] {
+			logger.Printf("Replay: client sent unrecorded %v\n", unexpected)
+			diverged++
+		}
+	}
+
+	return diverged
+}
+
+// Collects the frames the client sends to a replayed peer. The channel is closed when the pipe is.
+func readReplayed(ipport string, conn net.Conn, frames chan *Frame) {
+	defer close(frames)
+
+	for {
+		header, payload, err := p2p.RcvData_(conn)
+		if err != nil {
+			return
+		}
+
+		frames <- &Frame{Time: time.Now(), Peer: ipport, Outbound: true, TypeID: header.TypeID, Payload: payload}
+	}
+}
+
+// Waits until the client sends the recorded frame. Frames sent in between are logged as unrecorded.
+func awaitReplayed(expected *Frame, frames chan *Frame) bool {
+	timeout := time.After(util.FETCH_TIMEOUT * time.Second)
+
+	for {
+		select {
+		case frame := <-frames:
+			if frame.TypeID == expected.TypeID && bytes.Equal(frame.Payload, expected.Payload) {
+				return true
+			}
+
+			logger.Printf("Replay: client sent unrecorded %v, waiting for %v\n", frame, expected)
+		case <-timeout:
+			logger.Printf("Replay: client did not send recorded %v\n", expected)
+			return false
+		}
+	}
+}
@@ -8,112 +8,87 @@ import (
 	"github.com/way365/bazo-miner/protocol"
 )
 
-func BlockReq(blockHash []byte) error {
-	p := peers.getRandomPeer()
-	if p == nil {
-		return errors.New("Couldn't get a connection, request not transmitted.")
-	}
+// Maps the tx request types to the types of their responses.
+var txResponses = map[uint8]uint8{
+	p2p.FUNDSTX_REQ:  p2p.FUNDSTX_RES,
+	p2p.ACCTX_REQ:    p2p.ACCTX_RES,
+	p2p.CONFIGTX_REQ: p2p.CONFIGTX_RES,
+	p2p.STAKETX_REQ:  p2p.STAKETX_RES,
+}
 
-	packet := p2p.BuildPacket(p2p.BLOCK_REQ, blockHash[:])
-	sendData(p, packet)
+func BlockReq(blockHash []byte) (*Future, error) {
+	return request(p2p.BLOCK_REQ, p2p.BLOCK_RES, blockHash, blockHash[:])
+}
 
-	return nil
+// Requests the header with the given hash, or the latest header if blockHash is nil.
+func BlockHeaderReq(blockHash []byte) (*Future, error) {
+	return quorumRequest(p2p.BLOCK_HEADER_REQ, p2p.BlOCK_HEADER_RES, blockHash, blockHash[:])
 }
 
-func BlockHeaderReq(blockHash []byte) error {
-	p := peers.getRandomPeer()
-	if p == nil {
-		return errors.New("Couldn't get a connection, request not transmitted.")
+func TxReq(txType uint8, txHash [32]byte) (*Future, error) {
+	resType, ok := txResponses[txType]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown tx request type %v.", txType))
 	}
 
-	packet := p2p.BuildPacket(p2p.BLOCK_HEADER_REQ, blockHash[:])
-	sendData(p, packet)
-
-	return nil
+	return request(txType, resType, txHash[:], txHash[:])
 }
 
-func TxReq(txType uint8, txHash [32]byte) error {
-	p := peers.getRandomPeer()
-	if p == nil {
-		return errors.New("Couldn't get a connection, request not transmitted.")
+func AccReq(root bool, addressHash [32]byte) (*Future, error) {
+	if root {
+		return quorumRequest(p2p.ROOTACC_REQ, p2p.ROOTACC_RES, addressHash[:], addressHash[:])
 	}
 
-	packet := p2p.BuildPacket(txType, txHash[:])
-	sendData(p, packet)
-
-	return nil
+	return quorumRequest(p2p.ACC_REQ, p2p.ACC_RES, addressHash[:], addressHash[:])
 }
 
-func AccReq(root bool, addressHash [32]byte) error {
-	p := peers.getRandomPeer()
-	if p == nil {
-		return errors.New("Couldn't get a connection, request not transmitted.")
-	}
+// Requests the account from the quorum, or from all connected miners if the quorum mode is disabled. The future
+// fails unless they agree.
+func UnanimousAccReq(addressHash [32]byte) (*Future, error) {
+	return unanimousRequest(p2p.ACC_REQ, p2p.ACC_RES, addressHash[:], addressHash[:])
+}
 
-	var packet []byte
-	if root {
-		packet = p2p.BuildPacket(p2p.ROOTACC_REQ, addressHash[:])
-	} else {
-		packet = p2p.BuildPacket(p2p.ACC_REQ, addressHash[:])
+// Sends a tx to a single endpoint, such as the multisig server. Use BroadcastTx to submit a tx to the miners.
+func SendTx(dial string, tx protocol.Transaction, typeID uint8) (err error) {
+	if _, err := submitTx(dial, p2p.BuildPacket(typeID, tx.Encode())); err != nil {
+		txHash := tx.Hash()
+		return errors.New(fmt.Sprintf("Sending tx %x to %v failed: %v", txHash[:8], dial, err))
 	}
 
-	sendData(p, packet)
-
 	return nil
 }
 
-func SendTx(dial string, tx protocol.Transaction, typeID uint8) (err error) {
-	if conn := p2p.Connect(dial); conn != nil {
-		packet := p2p.BuildPacket(typeID, tx.Encode())
-		conn.Write(packet)
+func NonVerifiedTxReq(addressHash [32]byte) (nonVerifiedTxs []*protocol.FundsTx) {
+	conn, err := dialPeer(util.Config.MultisigIpport)
+	if err != nil {
+		logger.Printf("Requesting non verified tx failed: %v\n", err)
+		return nil
+	}
+	defer conn.Close()
 
-		header, payload, err := p2p.RcvData_(conn)
-		if err != nil || header.TypeID == p2p.NOT_FOUND {
-			err = errors.New(string(payload[:]))
-		}
-		conn.Close()
+	packet := p2p.BuildPacket(p2p.FUNDSTX_REQ, addressHash[:])
+	conn.Write(packet)
 
-		return err
+	header, payload, err := p2p.RcvData_(conn)
+	if err != nil || header.TypeID != p2p.FUNDSTX_RES {
+		logger.Printf("Requesting non verified tx failed.")
+		return nil
 	}
 
-	txHash := tx.Hash()
-	return errors.New(fmt.Sprintf("Sending tx %x failed.", txHash[:8]))
-}
-
-func NonVerifiedTxReq(addressHash [32]byte) (nonVerifiedTxs []*protocol.FundsTx) {
-	if conn := p2p.Connect(util.Config.MultisigIpport); conn != nil {
-		packet := p2p.BuildPacket(p2p.FUNDSTX_REQ, addressHash[:])
-		conn.Write(packet)
-
-		header, payload, err := p2p.RcvData_(conn)
-		if err != nil || header.TypeID != p2p.FUNDSTX_RES {
-			logger.Printf("Requesting non verified tx failed.")
-			return nil
-		}
-
-		for _, data := range protocol.Decode(payload, protocol.FUNDSTX_SIZE) {
-			var tx *protocol.FundsTx
-			nonVerifiedTxs = append(nonVerifiedTxs, tx.Decode(data))
-		}
+	for _, data := range protocol.Decode(payload, protocol.FUNDSTX_SIZE) {
+		var tx *protocol.FundsTx
+		nonVerifiedTxs = append(nonVerifiedTxs, tx.Decode(data))
 	}
 
 	return nonVerifiedTxs
 }
 
-func IntermediateNodesReq(blockHash [32]byte, txHash [32]byte) error {
-	p := peers.getRandomPeer()
-	if p == nil {
-		return errors.New("Couldn't get a connection, request not transmitted.")
-	}
-
+func IntermediateNodesReq(blockHash [32]byte, txHash [32]byte) (*Future, error) {
 	var data [][]byte
 	data = append(data, blockHash[:])
 	data = append(data, txHash[:])
 
-	packet := p2p.BuildPacket(p2p.INTERMEDIATE_NODES_REQ, protocol.Encode(data, 32))
-	sendData(p, packet)
-
-	return nil
+	return request(p2p.INTERMEDIATE_NODES_REQ, p2p.INTERMEDIATE_NODES_RES, nil, protocol.Encode(data, 32))
 }
 
 func neighborReq() {
@@ -1,28 +1,45 @@
 package network
 
 import (
+	"context"
 	"encoding/binary"
 	"github.com/way365/bazo-miner/p2p"
 	"github.com/way365/bazo-miner/protocol"
+	"net"
 	"strconv"
 )
 
-func blockHeaderBrdcst(p *peer, payload []byte) {
+func blockHeaderBrdcst(ctx context.Context, p *peer, payload []byte) {
 	var blockHeader *protocol.Block
 	blockHeader = blockHeader.Decode(payload)
-	BlockHeaderIn <- blockHeader
+
+	select {
+	case BlockHeaderIn <- &ReceivedBlockHeader{blockHeader, p.getIPPort()}:
+	case <-ctx.Done():
+	}
 }
 
 func blockRes(p *peer, payload []byte) {
-	var blockHeader *protocol.Block
-	blockHeader = blockHeader.Decode(payload)
-	BlockChan <- blockHeader
+	var block *protocol.Block
+	block = block.Decode(payload)
+	if block == nil {
+		resolve(p, p2p.BLOCK_RES, [32]byte{}, false, block)
+		return
+	}
+
+	resolve(p, p2p.BLOCK_RES, block.Hash, true, block)
 }
 
 func blockHeaderRes(p *peer, payload []byte) {
 	var blockHeader *protocol.Block
 	blockHeader = blockHeader.Decode(payload)
-	BlockHeaderChan <- blockHeader
+	received := &ReceivedBlockHeader{blockHeader, p.getIPPort()}
+	if blockHeader == nil {
+		resolve(p, p2p.BlOCK_HEADER_RES, [32]byte{}, false, received)
+		return
+	}
+
+	resolve(p, p2p.BlOCK_HEADER_RES, blockHeader.Hash, true, received)
 }
 
 func txRes(p *peer, payload []byte, txType uint8) {
@@ -30,43 +47,48 @@ func txRes(p *peer, payload []byte, txType uint8) {
 		return
 	}
 
+	var tx protocol.Transaction
 	switch txType {
 	case p2p.FUNDSTX_RES:
 		var fundsTx *protocol.FundsTx
-		fundsTx = fundsTx.Decode(payload)
-		if fundsTx == nil {
-			return
+		if fundsTx = fundsTx.Decode(payload); fundsTx != nil {
+			tx = fundsTx
 		}
-		FundsTxChan <- fundsTx
 	case p2p.ACCTX_RES:
 		var accTx *protocol.AccTx
-		accTx = accTx.Decode(payload)
-		if accTx == nil {
-			return
+		if accTx = accTx.Decode(payload); accTx != nil {
+			tx = accTx
 		}
-		AccTxChan <- accTx
 	case p2p.CONFIGTX_RES:
 		var configTx *protocol.ConfigTx
-		configTx = configTx.Decode(payload)
-		if configTx == nil {
-			return
+		if configTx = configTx.Decode(payload); configTx != nil {
+			tx = configTx
 		}
-		ConfigTxChan <- configTx
 	case p2p.STAKETX_RES:
 		var stakeTx *protocol.StakeTx
-		stakeTx = stakeTx.Decode(payload)
-		if stakeTx == nil {
-			return
+		if stakeTx = stakeTx.Decode(payload); stakeTx != nil {
+			tx = stakeTx
 		}
-		StakeTxChan <- stakeTx
 	}
+
+	if tx == nil {
+		return
+	}
+
+	resolve(p, txType, tx.Hash(), true, tx)
 }
 
-func accRes(p *peer, payload []byte) {
+func accRes(p *peer, payload []byte, resType uint8) {
 	var acc *protocol.Account
 	acc = acc.Decode(payload)
 
-	AccChan <- acc
+	//Miners answer with an empty account if the address is unknown.
+	if acc == nil || acc.Address == [64]byte{} {
+		resolve(p, resType, [32]byte{}, false, acc)
+		return
+	}
+
+	resolve(p, resType, protocol.SerializeHashContent(acc.Address), true, acc)
 }
 
 func intermediateNodesRes(p *peer, payload []byte) {
@@ -77,39 +99,38 @@ func intermediateNodesRes(p *peer, payload []byte) {
 		nodes = append(nodes, node)
 	}
 
-	IntermediateNodesChan <- nodes
+	resolve(p, p2p.INTERMEDIATE_NODES_RES, [32]byte{}, false, nodes)
 }
 
-func processNeighborRes(p *peer, payload []byte) {
+// Delivers a response to the request it answers. Unrequested and late responses are logged and dropped.
+func resolve(p *peer, resType uint8, key [32]byte, keyed bool, payload interface{}) {
+	if !pending.resolve(p, resType, key, keyed, payload) {
+		logger.Printf("Dropped unmatched %v from %v\n", p2p.LogMapping[resType], p.getIPPort())
+	}
+}
 
-	//Parse the incoming ipv4 addresses.
-	ipportList := _processNeighborRes(payload)
+func processNeighborRes(p *peer, payload []byte) {
+	ipportList := parseNeighborRes(payload)
 
 	for _, ipportIter := range ipportList {
 		logger.Printf("IP/Port received: %v\n", ipportIter)
-		//iplistChan is a buffered channel to handle ips asynchronously.
-		iplistChan <- ipportIter
+		candidates.add(ipportIter)
 	}
+
+	//Only the broadcast waits for the neighbors, the health service does not.
+	pending.resolve(p, p2p.NEIGHBOR_RES, [32]byte{}, false, ipportList)
 }
 
-// Split the processNeighborRes function in two for cleaner testing.
-func _processNeighborRes(payload []byte) (ipportList []string) {
-	index := 0
-	for cnt := 0; cnt < len(payload)/(p2p.IPV4ADDR_SIZE+p2p.PORT_SIZE); cnt++ {
-		var addr string
-		for singleAddr := index; singleAddr < index+p2p.IPV4ADDR_SIZE; singleAddr++ {
-			tmp := int(payload[singleAddr])
-			addr += strconv.Itoa(tmp)
-			addr += "."
-		}
-		//Remove trailing dot.
-		addr = addr[:len(addr)-1]
-		addr += ":"
-		//Extract port number.
-		addr += strconv.Itoa(int(binary.BigEndian.Uint16(payload[index+4 : index+6])))
-
-		ipportList = append(ipportList, addr)
-		index += p2p.IPV4ADDR_SIZE + p2p.PORT_SIZE
+// Parses the NEIGHBOR_RES the miner sends: a 4 byte ipv4 address followed by the 2 byte port per entry. An
+// incomplete trailing entry is ignored. Miners do not list neighbors without an ipv4 address, learning those
+// requires a change to the miner's neighbor response.
+func parseNeighborRes(payload []byte) (ipportList []string) {
+	for index := 0; index+p2p.IPV4ADDR_SIZE+p2p.PORT_SIZE <= len(payload); index += p2p.IPV4ADDR_SIZE + p2p.PORT_SIZE {
+		ip := net.IP(payload[index : index+p2p.IPV4ADDR_SIZE])
+		port := binary.BigEndian.Uint16(payload[index+p2p.IPV4ADDR_SIZE : index+p2p.IPV4ADDR_SIZE+p2p.PORT_SIZE])
+
+		ipportList = append(ipportList, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
 	}
+
 	return ipportList
 }
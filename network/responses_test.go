@@ -0,0 +1,30 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNeighborRes(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    []string
+	}{
+		{"empty", nil, nil},
+		{"single ipv4", []byte{127, 0, 0, 1, 0x1f, 0x90}, []string{"127.0.0.1:8080"}},
+		{"multiple ipv4", []byte{10, 0, 0, 1, 0x1f, 0x90, 192, 168, 1, 20, 0x00, 0x50}, []string{"10.0.0.1:8080", "192.168.1.20:80"}},
+		{"truncated entry ignored", []byte{10, 0, 0, 1, 0x1f, 0x90, 192, 168, 1}, []string{"10.0.0.1:8080"}},
+		{"only truncated entry", []byte{10, 0, 0}, nil},
+		//A first byte of 4 or 16 does not change how the entries are read.
+		{"first byte looks like a size", []byte{4, 1, 2, 3, 0x1f, 0x90, 16, 0, 0, 1, 0x00, 0x50}, []string{"4.1.2.3:8080", "16.0.0.1:80"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseNeighborRes(test.payload); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseNeighborRes(%v) = %v, want %v", test.payload, got, test.want)
+			}
+		})
+	}
+}
@@ -1,68 +1,58 @@
 package network
 
 import (
+	"context"
 	"github.com/way365/bazo-client/util"
 	"time"
 )
 
-// Single goroutine that makes sure the system is well connected.
-func checkHealthService() {
-	for {
-		time.Sleep(util.HEALTH_CHECK_INTERVAL * time.Second)
+// Single goroutine that makes sure the system is well connected. It keeps util.Config.MinerConnections
+// connections, dialing the known miners whose backoff ran out and asking for more miners if there are too few.
+func checkHealthService(ctx context.Context) {
+	defer running.Done()
 
-		//Stop trying to connect after #numberOfRetry attempts.
-		numberOfRetry := 0
+	lastNeighborReq := time.Time{}
 
-		if !peers.contains(util.Config.BootstrapIpport) {
-			p, err := initiateNewClientConnection(util.Config.BootstrapIpport)
-			if p == nil || err != nil {
-				logger.Printf("%v\n", err)
-			} else {
-				go minerConn(p)
+	for {
+		for _, ipport := range candidates.dialable() {
+			if len(peers.getAllPeers()) >= util.Config.MinerConnections || ctx.Err() != nil {
+				break
 			}
+
+			connect(ctx, ipport)
 		}
 
-		//Periodically check if we are well-connected
-		if len(peers.minerConns) >= util.MIN_MINERS {
-			continue
+		//Periodically ask for more miners if we are not well-connected
+		if len(peers.getAllPeers()) < util.Config.MinerConnections && time.Since(lastNeighborReq) >= util.HEALTH_CHECK_INTERVAL*time.Second {
+			neighborReq()
+			lastNeighborReq = time.Now()
 		}
 
-		//The only goto in the code (I promise), but best solution here IMHO.
-	RETRY:
 		select {
-		//iplistChan gets filled with every incoming neighborRes, they're consumed here.
-		case ipaddr := <-iplistChan:
-			p, err := initiateNewClientConnection(ipaddr)
-			if err != nil {
-				logger.Printf("%v\n", err)
-			}
-
-			if p == nil || err != nil {
-				if numberOfRetry < 3 {
-					numberOfRetry++
-					goto RETRY
-				} else {
-					break
-				}
-			}
-
-			go minerConn(p)
-			break
-		default:
-			//In case we don't have any ip addresses in the channel left, make a request to the network.
-			neighborReq()
-			break
+		case <-time.After(util.RECONNECT_BACKOFF_MIN * time.Second):
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func peerService() {
+func peerService(ctx context.Context) {
+	defer running.Done()
+
 	for {
 		select {
+		case <-ctx.Done():
+			//Closing the connections ends their minerConn goroutines, which fail the pending requests.
+			for _, p := range peers.getAllPeers() {
+				p.conn.Close()
+			}
+
+			return
 		case p := <-register:
 			peers.add(p)
 		case p := <-disconnect:
 			peers.delete(p)
+			pending.cancel(p)
 			close(p.ch)
 		}
 	}
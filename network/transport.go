@@ -0,0 +1,96 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/util"
+	"net"
+	"time"
+)
+
+var ErrKeyNotPinned = errors.New("no pinned key configured")
+
+// Opens a connection to a miner or the multisig server. bazo-miner has no TLS listener, in TLS mode ipport is the
+// address of a TLS-terminating proxy in front of the miner. The handshake completes, and with it the pinned key of
+// the proxy is verified, before the connection is returned, so no payload is exchanged with an unauthenticated peer.
+func dialPeer(ipport string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   util.FETCH_TIMEOUT * time.Second,
+		KeepAlive: 1 * time.Minute,
+	}
+
+	if !util.Config.TLS.Enabled {
+		return dialer.Dial("tcp", ipport)
+	}
+
+	config, err := tlsConfig(ipport)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("TLS connection to %v refused: %v", ipport, err))
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", ipport, config)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("TLS connection to %v failed: %v", ipport, err))
+	}
+
+	return conn, nil
+}
+
+// The certificate chain of a proxy is not verified against CAs, proxies in front of miners use self-signed
+// certificates. Instead the SHA-256 hash of the proxy's public key has to match the key pinned for its address.
+func tlsConfig(ipport string) (*tls.Config, error) {
+	pin, pinned := util.Config.TLS.PinnedKeys[ipport]
+	if !pinned && !util.Config.TLS.AllowUnpinned {
+		return nil, ErrKeyNotPinned
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	}
+
+	if pinned {
+		expected, err := hex.DecodeString(pin)
+		if err != nil || len(expected) != sha256.Size {
+			return nil, errors.New(fmt.Sprintf("pinned key %v is not a hex encoded SHA-256 hash", pin))
+		}
+
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPinnedKey(rawCerts, expected)
+		}
+	}
+
+	if util.Config.TLS.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(util.Config.TLS.Cert, util.Config.TLS.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func verifyPinnedKey(rawCerts [][]byte, expected []byte) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer sent no certificate")
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	if !bytes.Equal(hash[:], expected) {
+		return errors.New(fmt.Sprintf("public key %x does not match the pinned key", hash))
+	}
+
+	return nil
+}
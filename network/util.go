@@ -1,73 +1,67 @@
 package network
 
 import (
-	"bufio"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/p2p"
+	"io"
+	"net"
 	"time"
 )
 
-func Fetch(channelToFetchFrom chan interface{}) (payload interface{}, err error) {
-	select {
-	case payload = <-channelToFetchFrom:
-	case <-time.After(util.FETCH_TIMEOUT * time.Second):
-		return nil, errors.New("Fetching timed out.")
-	}
-
-	return payload, nil
-}
-
-func Fetch32Bytes(channelToFetchFrom chan [][32]byte) (payload [][32]byte, err error) {
-	select {
-	case payload = <-channelToFetchFrom:
-	case <-time.After(util.FETCH_TIMEOUT * time.Second):
-		return nil, errors.New("Fetching timed out.")
-	}
-
-	return payload, nil
-}
-
+// Reads a single frame. The connection may idle until the frame starts, the rest of the frame has to arrive within
+// util.FRAME_READ_TIMEOUT. Oversized, truncated and timed out frames close the connection and count as misbehavior.
 func rcvData(p *peer) (header *p2p.Header, payload []byte, err error) {
-	reader := bufio.NewReader(p.conn)
-	header, err = readHeader(reader)
+	var headerArr [p2p.HEADER_LEN]byte
 
-	if err != nil {
+	p.conn.SetReadDeadline(time.Time{})
+	if _, err = io.ReadFull(p.conn, headerArr[:1]); err != nil {
 		p.conn.Close()
 		return nil, nil, errors.New(fmt.Sprintf("Connection to %v aborted: %v", p.getIPPort(), err))
 	}
-	payload = make([]byte, header.Len)
 
-	for cnt := 0; cnt < int(header.Len); cnt++ {
-		payload[cnt], err = reader.ReadByte()
-		if err != nil {
-			p.conn.Close()
-			return nil, nil, errors.New(fmt.Sprintf("Connection to %v aborted: %v", p.getIPPort(), err))
-		}
+	p.conn.SetReadDeadline(time.Now().Add(util.FRAME_READ_TIMEOUT * time.Second))
+	if _, err = io.ReadFull(p.conn, headerArr[1:]); err != nil {
+		return nil, nil, frameError(p, &p2p.Header{}, err)
 	}
 
-	// logger.Printf("Receive message:\nSender: %v\nType: %v\nPayload length: %v\n", p.getIPPort(), p2p.LogMapping[header.TypeID], len(payload))
+	header = extractHeader(headerArr[:])
+	if header.Len > maxPayloadSize(header.TypeID) {
+		return nil, nil, frameError(p, header, nil)
+	}
+
+	payload = make([]byte, header.Len)
+	if _, err = io.ReadFull(p.conn, payload); err != nil {
+		return nil, nil, frameError(p, header, err)
+	}
+
+	record(p, false, header.TypeID, payload)
 
 	return header, payload, nil
 }
 
-func readHeader(reader *bufio.Reader) (*p2p.Header, error) {
-	//the first four bytes of any incoming messages is the length of the payload
-	//error catching after every read is necessary to avoid panicking
-	var headerArr [p2p.HEADER_LEN]byte
-	//reading byte by byte is surprisingly fast and works a lot better for concurrent connections
-	for i := range headerArr {
-		extr, err := reader.ReadByte()
-		if err != nil {
-			return nil, err
-		}
-		headerArr[i] = extr
+// Closes the connection and records the misbehavior. A nil err means the frame was oversized.
+func frameError(p *peer, header *p2p.Header, err error) error {
+	p.conn.Close()
+
+	reason := ErrFrameOversized
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		reason = ErrFrameTimeout
+	} else if err != nil {
+		reason = ErrFrameTruncated
 	}
 
-	header := extractHeader(headerArr[:])
-	return header, nil
+	frameErr := &FrameError{
+		Peer:   p.getIPPort(),
+		TypeID: header.TypeID,
+		Len:    header.Len,
+		Reason: reason,
+	}
+	misbehavior.record(frameErr)
+
+	return frameErr
 }
 
 // Decoupled functionality for testing reasons
@@ -84,7 +78,9 @@ func extractHeader(headerData []byte) *p2p.Header {
 }
 
 func sendData(p *peer, payload []byte) {
-	// logger.Printf("Send message:\nReceiver: %v\nType: %v\nPayload length: %v\n", p.getIPPort(), p2p.LogMapping[payload[4]], len(payload)-p2p.HEADER_LEN)
+	if len(payload) >= p2p.HEADER_LEN {
+		record(p, true, payload[4], payload[p2p.HEADER_LEN:])
+	}
 
 	p.l.Lock()
 	p.conn.Write(payload)
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"encoding/hex"
 	"github.com/way365/bazo-client/args"
 	"github.com/way365/bazo-client/cstorage"
 	"github.com/way365/bazo-client/network"
@@ -11,17 +13,35 @@ import (
 )
 
 type Account struct {
-	Address       [64]byte `json:"-"`
-	AddressString string   `json:"address"`
-	Balance       uint64   `json:"balance"`
-	TxCnt         uint32   `json:"txCnt"`
-	IsCreated     bool     `json:"isCreated"`
-	IsRoot        bool     `json:"isRoot"`
-	IsStaking     bool     `json:"isStaking"`
+	Address        [64]byte `json:"-"`
+	AddressString  string   `json:"address"`
+	Balance        uint64   `json:"balance"`
+	TxCnt          uint32   `json:"txCnt"`
+	IsCreated      bool     `json:"isCreated"`
+	IssuanceHeight uint32   `json:"issuanceHeight"`
+	IsRoot         bool     `json:"isRoot"`
+	IsStaking      bool     `json:"isStaking"`
 }
 
-func PrepareSignSubmitCreateAccTx(arguments *args.CreateAccountArgs, logger *log.Logger) (txHash [32]byte, err error) {
-	txHash, tx, err := PrepareCreateAccountTx(arguments, logger)
+// The account state as computed by the light client from headers, bloom filters and Merkle-verified transactions.
+type AccountState struct {
+	Account        *Account       `json:"account"`
+	LastTenTx      []*FundsTxJson `json:"lastTenTx"`
+	HeadersScanned int            `json:"headersScanned"`
+	BlocksScanned  int            `json:"blocksScanned"`
+	TxVerified     int            `json:"txVerified"`
+
+	//Every time the account joined or left the validator pool.
+	StakingHistory []*StakingEventJson `json:"stakingHistory"`
+
+	//The last header the state is computed for and the verified incoming transactions behind LastTenTx.
+	tip           *protocol.Block
+	verifiedTx    []*protocol.FundsTx
+	stakingEvents []cstorage.StakingEvent
+}
+
+func (client *Client) PrepareSignSubmitCreateAccTx(arguments *args.CreateAccountArgs, logger *log.Logger) (txHash [32]byte, err error) {
+	txHash, tx, err := client.PrepareCreateAccountTx(arguments, logger)
 	if err != nil {
 		return [32]byte{}, err
 	}
@@ -31,22 +51,22 @@ func PrepareSignSubmitCreateAccTx(arguments *args.CreateAccountArgs, logger *log
 		return [32]byte{}, err
 	}
 
-	if err := SignTx(txHash, tx, issuerPrivKey); err != nil {
+	if err := client.SignTx(txHash, tx, issuerPrivKey); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	if err := SubmitTx(txHash, tx); err != nil {
+	if _, err := client.SubmitTx(txHash, tx); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
 
 	return txHash, nil
 }
 
-func PrepareCreateAccountTx(arguments *args.CreateAccountArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.AccTx, err error) {
+func (client *Client) PrepareCreateAccountTx(arguments *args.CreateAccountArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.AccTx, err error) {
 	err = arguments.ValidateInput()
 	if err != nil {
 		return [32]byte{}, tx, err
@@ -89,19 +109,20 @@ func PrepareCreateAccountTx(arguments *args.CreateAccountArgs, logger *log.Logge
 	}
 
 	txHash = tx.ChameleonHash(parameters)
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
+	client.txPrepared(txHash)
 
 	return txHash, tx, err
 }
 
 func GetAccount(address [64]byte) (account *protocol.Account, err error) {
 
-	err = network.AccReq(false, protocol.SerializeHashContent(address))
+	future, err := network.AccReq(false, protocol.SerializeHashContent(address))
 	if err != nil {
 		return account, err
 	}
 
-	payload, err := network.Fetch(network.AccChan)
+	payload, err := future.Get()
 	if err != nil {
 		return account, err
 	}
@@ -114,7 +135,7 @@ func GetAccount(address [64]byte) (account *protocol.Account, err error) {
 	return account, nil
 }
 
-func AddAccount(arguments *args.AddAccountArgs, logger *log.Logger) error {
+func (client *Client) AddAccount(arguments *args.AddAccountArgs, logger *log.Logger) error {
 	err := arguments.ValidateInput()
 	if err != nil {
 		return err
@@ -151,33 +172,31 @@ func AddAccount(arguments *args.AddAccountArgs, logger *log.Logger) error {
 	}
 
 	txHash := tx.ChameleonHash(parameters)
+	client.store.WriteTransaction(txHash, tx)
+
+	_, err = client.SubmitTx(txHash, tx)
 
-	return SubmitTx(txHash, tx)
+	return err
 }
 
-func CheckAccount(args *args.CheckAccountArgs, logger *log.Logger) error {
+func (client *Client) CheckAccount(args *args.CheckAccountArgs, logger *log.Logger) error {
 	err := args.ValidateInput()
 	if err != nil {
 		return err
 	}
 
-	var address [64]byte
-	if len(args.Address) == 128 {
-		newPubInt, _ := new(big.Int).SetString(args.Address, 16)
-		copy(address[:], newPubInt.Bytes())
-	} else {
-		privKey, err := crypto.ExtractECDSAKeyFromFile(args.Wallet)
-		if err != nil {
-			logger.Printf("%v\n", err)
-			return err
-		}
-
-		address = crypto.GetAddressFromPubKey(&privKey.PublicKey)
+	address, err := resolveAddress(args)
+	if err != nil {
+		logger.Printf("%v\n", err)
+		return err
 	}
 
 	logger.Printf("My Address: %x\n", address)
 
-	loadBlockHeaders()
+	if err := client.loadBlockHeaders(); err != nil {
+		return err
+	}
+
 	acc, err := GetAccount(address)
 	if err != nil {
 		logger.Println(err)
@@ -188,3 +207,97 @@ func CheckAccount(args *args.CheckAccountArgs, logger *log.Logger) error {
 
 	return nil
 }
+
+// Computes the account's balance with the light client instead of trusting the state a miner returns.
+func (client *Client) CheckAccountBalance(ctx context.Context, args *args.CheckAccountArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	address, err := resolveAddress(args)
+	if err != nil {
+		logger.Printf("%v\n", err)
+		return err
+	}
+
+	logger.Printf("My Address: %x\n", address)
+
+	if err := client.syncBlockHeaders(ctx); err != nil {
+		return err
+	}
+
+	state, err := client.GetAccountState(address)
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+
+	if state.Account.IsCreated {
+		logger.Printf("Account created at height %v\n", state.Account.IssuanceHeight)
+	} else {
+		logger.Println("Account creation not found on the synced chain")
+	}
+
+	logger.Printf("Verified balance: %v\nTx count: %v\nStaking: %v\nHeaders scanned: %v\nBlocks scanned: %v\nTransactions verified: %v\n",
+		state.Account.Balance,
+		state.Account.TxCnt,
+		state.Account.IsStaking,
+		state.HeadersScanned,
+		state.BlocksScanned,
+		state.TxVerified)
+
+	for _, tx := range state.LastTenTx {
+		if tx != nil {
+			logger.Printf("Received %v from %v (%v), TxHash: %v\n", tx.Amount, tx.From, tx.Status, tx.Hash)
+		}
+	}
+
+	for _, event := range state.StakingHistory {
+		if event.IsStaking {
+			logger.Printf("Joined the validator pool at height %v, TxHash: %v\n", event.Height, event.TxHash)
+		} else {
+			logger.Printf("Left the validator pool at height %v, TxHash: %v\n", event.Height, event.TxHash)
+		}
+	}
+
+	return nil
+}
+
+func (client *Client) GetAccountState(address [64]byte) (state *AccountState, err error) {
+	state = &AccountState{
+		Account: &Account{
+			Address:       address,
+			AddressString: hex.EncodeToString(address[:]),
+		},
+		LastTenTx: make([]*FundsTxJson, 10),
+		tip:       client.lastHeader(),
+	}
+
+	from := client.restoreCheckpoint(state)
+
+	if err := client.getState(state, from); err != nil {
+		return nil, err
+	}
+
+	client.saveCheckpoint(state)
+	getNonVerifiedState(state)
+
+	return state, nil
+}
+
+func resolveAddress(args *args.CheckAccountArgs) (address [64]byte, err error) {
+	if len(args.Address) == 128 {
+		newPubInt, _ := new(big.Int).SetString(args.Address, 16)
+		copy(address[:], newPubInt.Bytes())
+
+		return address, nil
+	}
+
+	privKey, err := crypto.ExtractECDSAKeyFromFile(args.Wallet)
+	if err != nil {
+		return address, err
+	}
+
+	return crypto.GetAddressFromPubKey(&privKey.PublicKey), nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"encoding/hex"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/protocol"
+)
+
+type AccountTxJson struct {
+	TxHash    string `json:"txHash"`
+	TxType    string `json:"txType"`
+	Status    string `json:"status"`
+	Height    uint32 `json:"height,omitempty"`
+	BlockHash string `json:"blockHash,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Amount    uint64 `json:"amount"`
+	Fee       uint64 `json:"fee"`
+}
+
+type AccountHistoryJson struct {
+	Address  string           `json:"address"`
+	Total    int              `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+	Txs      []*AccountTxJson `json:"txs"`
+}
+
+func ConvertAccountTx(indexed *cstorage.IndexedTx, tx protocol.Transaction) (accountTxJson *AccountTxJson) {
+	accountTxJson = &AccountTxJson{
+		TxHash: hex.EncodeToString(indexed.TxHash[:]),
+		Status: "not verified",
+	}
+
+	if indexed.Height != cstorage.PENDING_HEIGHT {
+		accountTxJson.Status = "verified"
+		accountTxJson.Height = indexed.Height
+		accountTxJson.BlockHash = hex.EncodeToString(indexed.BlockHash[:])
+	}
+
+	var from, to [32]byte
+	switch tx := tx.(type) {
+	case *protocol.FundsTx:
+		accountTxJson.TxType = "funds"
+		from, to = tx.From, tx.To
+		accountTxJson.Amount = tx.Amount
+		accountTxJson.Fee = tx.Fee
+	case *protocol.AccTx:
+		accountTxJson.TxType = "acc"
+		from, to = tx.Issuer, protocol.SerializeHashContent(tx.PubKey)
+		accountTxJson.Fee = tx.Fee
+	case *protocol.StakeTx:
+		accountTxJson.TxType = "stake"
+		from = tx.Account
+		accountTxJson.Fee = tx.Fee
+	case *protocol.UpdateTx:
+		accountTxJson.TxType = "update"
+		from = tx.Issuer
+		accountTxJson.Fee = tx.Fee
+	}
+
+	if from != [32]byte{} {
+		accountTxJson.From = hex.EncodeToString(from[:])
+	}
+
+	if to != [32]byte{} {
+		accountTxJson.To = hex.EncodeToString(to[:])
+	}
+
+	return accountTxJson
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"encoding/hex"
+	"github.com/way365/bazo-client/cstorage"
+)
+
+type ActivityJson struct {
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockHash"`
+	TxHash    string `json:"txHash"`
+	TxType    string `json:"txType"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Amount    uint64 `json:"amount"`
+	Fee       uint64 `json:"fee"`
+	IsStaking bool   `json:"isStaking"`
+}
+
+func ConvertActivity(activity *cstorage.Activity) (activityJson *ActivityJson) {
+	activityJson = &ActivityJson{
+		Height:    activity.Height,
+		BlockHash: hex.EncodeToString(activity.BlockHash[:]),
+		TxHash:    hex.EncodeToString(activity.TxHash[:]),
+		TxType:    activity.TxType,
+		Amount:    activity.Amount,
+		Fee:       activity.Fee,
+		IsStaking: activity.IsStaking,
+	}
+
+	if activity.From != [32]byte{} {
+		activityJson.From = hex.EncodeToString(activity.From[:])
+	}
+
+	if activity.To != [32]byte{} {
+		activityJson.To = hex.EncodeToString(activity.To[:])
+	}
+
+	return activityJson
+}
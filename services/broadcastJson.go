@@ -0,0 +1,30 @@
+package services
+
+import (
+	"github.com/way365/bazo-client/network"
+)
+
+type TxSubmissionJson struct {
+	Peer     string `json:"peer"`
+	Accepted bool   `json:"accepted"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+func ConvertBroadcastResult(result *network.BroadcastResult) (submissions []*TxSubmissionJson) {
+	for _, submission := range result.Submissions {
+		submissionJson := &TxSubmissionJson{
+			Peer:     submission.Peer,
+			Accepted: submission.Accepted,
+			Attempts: submission.Attempts,
+		}
+
+		if submission.Err != nil {
+			submissionJson.Error = submission.Err.Error()
+		}
+
+		submissions = append(submissions, submissionJson)
+	}
+
+	return submissions
+}
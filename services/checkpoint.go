@@ -0,0 +1,91 @@
+package services
+
+import (
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/protocol"
+)
+
+func init() {
+	onReorg((*Client).dropCheckpoints)
+}
+
+// Checkpoints at or above the first rolled back height were computed from rolled back blocks.
+func (client *Client) dropCheckpoints(reorg *cstorage.Reorg) {
+	client.store.DeleteCheckpointsFrom(reorg.Height)
+}
+
+// Restores the account's state from its checkpoint. Returns the first height that still has to be processed.
+func (client *Client) restoreCheckpoint(state *AccountState) (from uint32) {
+	addressHash := protocol.SerializeHashContent(state.Account.Address)
+
+	checkpoint := client.store.ReadCheckpoint(addressHash)
+	if checkpoint == nil {
+		return 0
+	}
+
+	//The checkpoint's header is no longer part of the synced chain, e.g. it was rolled back before the last start.
+	if client.store.ReadBlockHashByHeight(checkpoint.Height) != checkpoint.Hash {
+		client.store.DeleteCheckpoint(addressHash)
+		return 0
+	}
+
+	state.Account.Balance = checkpoint.Balance
+	state.Account.TxCnt = checkpoint.TxCnt
+	state.Account.IsCreated = checkpoint.IsCreated
+	state.Account.IssuanceHeight = checkpoint.IssuanceHeight
+	state.Account.IsStaking = checkpoint.IsStaking
+
+	for _, encodedTx := range checkpoint.LastTenTx {
+		var fundsTx *protocol.FundsTx
+		if fundsTx = fundsTx.Decode(encodedTx); fundsTx != nil {
+			put(state.LastTenTx, ConvertFundsTx(fundsTx, "verified"))
+			state.receiveVerified(fundsTx)
+		}
+	}
+
+	for _, event := range checkpoint.Staking {
+		state.stake(event)
+	}
+
+	return checkpoint.Height + 1
+}
+
+// Saves the verified state up to the header the state was computed for.
+func (client *Client) saveCheckpoint(state *AccountState) {
+	if state.tip == nil {
+		return
+	}
+
+	checkpoint := &cstorage.Checkpoint{
+		Balance:        state.Account.Balance,
+		TxCnt:          state.Account.TxCnt,
+		Height:         state.tip.Height,
+		Hash:           state.tip.Hash,
+		IsCreated:      state.Account.IsCreated,
+		IssuanceHeight: state.Account.IssuanceHeight,
+		IsStaking:      state.Account.IsStaking,
+		Staking:        state.stakingEvents,
+	}
+
+	for _, fundsTx := range state.verifiedTx {
+		checkpoint.LastTenTx = append(checkpoint.LastTenTx, fundsTx.Encode())
+	}
+
+	addressHash := protocol.SerializeHashContent(state.Account.Address)
+	if err := client.store.WriteCheckpoint(addressHash, checkpoint); err != nil {
+		logger.Printf("Saving checkpoint for %x failed: %v\n", addressHash[:8], err)
+	}
+}
+
+func (state *AccountState) receiveVerified(fundsTx *protocol.FundsTx) {
+	state.verifiedTx = append(state.verifiedTx, fundsTx)
+	if len(state.verifiedTx) > 10 {
+		state.verifiedTx = state.verifiedTx[len(state.verifiedTx)-10:]
+	}
+}
+
+func (state *AccountState) stake(event cstorage.StakingEvent) {
+	state.Account.IsStaking = event.IsStaking
+	state.stakingEvents = append(state.stakingEvents, event)
+	state.StakingHistory = append(state.StakingHistory, ConvertStakingEvent(event))
+}
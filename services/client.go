@@ -0,0 +1,57 @@
+package services
+
+import (
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/protocol"
+	"sync"
+)
+
+// Syncs the chain from the network and keeps it, the stored tx and the derived state in its store. The connections
+// to the miners are shared, clients with different stores can be used side by side.
+type Client struct {
+	store cstorage.Store
+
+	//The last header of the synced chain. All other headers are read from the store by height. Read by the services
+	//while the header sync replaces it, hence it is only accessed through lastHeader and setLastHeader.
+	lastBlockHeader *protocol.Block
+	tipLock         sync.RWMutex
+
+	//Tracks the goroutines started by Sync, so that Wait can block until they returned.
+	running sync.WaitGroup
+
+	//Serializes the watch list sync with changes to the watch list and the removal of rolled back activity.
+	watchLock sync.Mutex
+
+	//Buffered, so that the header sync never waits for the watch service.
+	watchTrigger chan bool
+
+	//Serializes the updates of the lifecycle records, they are read, changed and written back.
+	txStatusLock sync.Mutex
+
+	//Buffered, so that the header sync never waits for the tx status service.
+	txStatusTrigger chan bool
+}
+
+// Returns a client that reads from and writes to store.
+func NewClient(store cstorage.Store) *Client {
+	return &Client{
+		store:           store,
+		watchTrigger:    make(chan bool, 1),
+		txStatusTrigger: make(chan bool, 1),
+	}
+}
+
+// Returns the last header of the synced chain, nil if no header is synced yet.
+func (client *Client) lastHeader() *protocol.Block {
+	client.tipLock.RLock()
+	defer client.tipLock.RUnlock()
+
+	return client.lastBlockHeader
+}
+
+func (client *Client) setLastHeader(header *protocol.Block) {
+	client.tipLock.Lock()
+	defer client.tipLock.Unlock()
+
+	client.lastBlockHeader = header
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"log"
+	"os"
+)
+
+// Writes the whole DB to a JSON Lines archive.
+func (client *Client) ExportDb(args *args.DbArchiveArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	boltStore, err := client.archivedStore()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(args.File, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	records, err := boltStore.Export(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	logger.Printf("Exported %v records to %v\n", records, args.File)
+
+	return nil
+}
+
+// Restores a JSON Lines archive written by ExportDb, replacing the DB or merging the archive into it.
+func (client *Client) ImportDb(args *args.DbArchiveArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	boltStore, err := client.archivedStore()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(args.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, err := boltStore.Import(file, args.Merge)
+	if err != nil {
+		return err
+	}
+
+	if args.Merge {
+		logger.Printf("Merged %v records from %v\n", records, args.File)
+	} else {
+		logger.Printf("Restored %v records from %v\n", records, args.File)
+	}
+
+	return nil
+}
+
+// Only the bolt store outlives the process, the memory store has nothing to back up.
+func (client *Client) archivedStore() (*cstorage.BoltStore, error) {
+	boltStore, ok := client.store.(*cstorage.BoltStore)
+	if !ok {
+		return nil, errors.New("Backups require the bolt storage backend")
+	}
+
+	return boltStore, nil
+}
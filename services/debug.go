@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-client/network"
+	"log"
+	"os"
+)
+
+// Replays a capture recorded with --record against a fresh DB. The client syncs the headers like the rest service
+// does, with the recorded miners answering from the capture.
+func ReplayCapture(ctx context.Context, args *args.ReplayArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	frames, err := network.ReadCapture(args.Capture)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(args.Db); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	replayStore, err := cstorage.OpenBoltStore(args.Db)
+	if err != nil {
+		return err
+	}
+	defer replayStore.Close()
+
+	//The replay syncs into its own DB, the client's DB is not touched.
+	replay := NewClient(replayStore)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer replay.Wait()
+	defer cancel()
+
+	logger.Printf("Replaying %v frames from %v into %v\n", len(frames), args.Capture, args.Db)
+
+	if err := replay.Sync(ctx); err != nil {
+		return err
+	}
+
+	diverged := network.Replay(ctx, frames)
+
+	if last := replayStore.ReadLastBlockHeader(); last != nil {
+		logger.Printf("Replay synced up to header %x with height %v\n", last.Hash[:8], last.Height)
+	}
+
+	if diverged > 0 {
+		return errors.New(fmt.Sprintf("Replay diverged from the capture at %v frames", diverged))
+	}
+
+	logger.Println("Replay matched the capture")
+
+	return nil
+}
@@ -1,40 +1,66 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"github.com/way365/bazo-client/network"
 	"github.com/way365/bazo-miner/protocol"
 )
 
 func getRelevantBlocks(relevantBlockHeaders []*protocol.Block) (relevantBlocks []*protocol.Block, err error) {
 	for _, blockHeader := range relevantBlockHeaders {
-		err := network.BlockReq(blockHeader.Hash[:])
+		future, err := network.BlockReq(blockHeader.Hash[:])
 		if err != nil {
 			return nil, err
 		}
 
-		blockI, err := network.Fetch(network.BlockChan)
+		blockI, err := future.Get()
 		if err != nil {
 			return nil, err
 		}
 
 		var block *protocol.Block
 		block = blockI.(*protocol.Block)
+		//The block's hash is recomputed, a peer could serve any block under the requested hash otherwise.
+		if block == nil || block.HashBlock() != blockHeader.Hash {
+			return nil, errors.New(fmt.Sprintf("Fetched block does not match header %x", blockHeader.Hash[:8]))
+		}
+
 		relevantBlocks = append(relevantBlocks, block)
 	}
 
 	return relevantBlocks, nil
 }
 
-func getRelevantBlockHeaders(pubKeyHash [32]byte) (relevantHeadersBeneficiary []*protocol.Block, relevantHeadersConfigBF []*protocol.Block) {
-	for _, blockHeader := range blockHeaders {
-		if blockHeader.Beneficiary == pubKeyHash {
-			relevantHeadersBeneficiary = append(relevantHeadersBeneficiary, blockHeader)
+// Scans the synced chain from height from up to height to in batches, so that only the relevant headers are held in memory.
+func (client *Client) getRelevantBlockHeaders(pubKeyHash [32]byte, from uint32, to uint32) (relevantHeadersBeneficiary []*protocol.Block, relevantHeadersConfigBF []*protocol.Block, headersScanned int) {
+	for ; from <= to; from += HEADER_BATCH_SIZE {
+		batchTo := from + HEADER_BATCH_SIZE - 1
+		if batchTo > to {
+			batchTo = to
 		}
 
-		if blockHeader.NrConfigTx > 0 || (blockHeader.NrElementsBF > 0 && blockHeader.BloomFilter.Test(pubKeyHash[:])) {
-			relevantHeadersConfigBF = append(relevantHeadersConfigBF, blockHeader)
+		for _, blockHeader := range client.store.ReadBlockHeadersByRange(from, batchTo) {
+			headersScanned++
+
+			if blockHeader.Beneficiary == pubKeyHash {
+				relevantHeadersBeneficiary = append(relevantHeadersBeneficiary, blockHeader)
+			}
+
+			if (blockHeader.NrConfigTx > 0 && blockHeader.Beneficiary == pubKeyHash) || (blockHeader.NrElementsBF > 0 && blockHeader.BloomFilter.Test(pubKeyHash[:])) {
+				relevantHeadersConfigBF = append(relevantHeadersConfigBF, blockHeader)
+			}
 		}
 	}
 
-	return relevantHeadersBeneficiary, relevantHeadersConfigBF
+	return relevantHeadersBeneficiary, relevantHeadersConfigBF, headersScanned
+}
+
+func fetchTx(txType uint8, txHash [32]byte) (txI interface{}, err error) {
+	future, err := network.TxReq(txType, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return future.Get()
 }
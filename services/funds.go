@@ -4,19 +4,18 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/cstorage"
 	"github.com/way365/bazo-miner/crypto"
 	"github.com/way365/bazo-miner/protocol"
 	"log"
 )
 
-func PrepareSignSubmitFundsTx(arguments *args.FundsArgs, logger *log.Logger) (txHash [32]byte, err error) {
+func (client *Client) PrepareSignSubmitFundsTx(arguments *args.FundsArgs, logger *log.Logger) (txHash [32]byte, err error) {
 	err = arguments.ValidateInput()
 	if err != nil {
 		return [32]byte{}, err
 	}
 
-	txHash, tx, err := PrepareFundsTx(arguments, logger)
+	txHash, tx, err := client.PrepareFundsTx(arguments, logger)
 
 	fromPrivKey, err := args.ResolvePrivateKey(arguments.From)
 	if err != nil {
@@ -28,22 +27,22 @@ func PrepareSignSubmitFundsTx(arguments *args.FundsArgs, logger *log.Logger) (tx
 		return [32]byte{}, err
 	}
 
-	if err := SignFundsTx(txHash, tx, fromPrivKey, multiSigPrivKey); err != nil {
+	if err := client.SignFundsTx(txHash, tx, fromPrivKey, multiSigPrivKey); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	if err := SubmitTx(txHash, tx); err != nil {
+	if _, err := client.SubmitTx(txHash, tx); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
 
 	return txHash, nil
 }
 
-func PrepareFundsTx(arguments *args.FundsArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.FundsTx, err error) {
+func (client *Client) PrepareFundsTx(arguments *args.FundsArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.FundsTx, err error) {
 	err = arguments.ValidateInput()
 	if err != nil {
 		return [32]byte{}, tx, err
@@ -86,12 +85,13 @@ func PrepareFundsTx(arguments *args.FundsArgs, logger *log.Logger) (txHash [32]b
 	}
 
 	txHash = tx.ChameleonHash(parameters)
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
+	client.txPrepared(txHash)
 
 	return txHash, tx, err
 }
 
-func SignFundsTx(txHash [32]byte, tx *protocol.FundsTx, privKey *ecdsa.PrivateKey, multiSigKey *ecdsa.PrivateKey) error {
+func (client *Client) SignFundsTx(txHash [32]byte, tx *protocol.FundsTx, privKey *ecdsa.PrivateKey, multiSigKey *ecdsa.PrivateKey) error {
 	r, s, err := ecdsa.Sign(rand.Reader, privKey, txHash[:])
 	if err != nil {
 		return err
@@ -110,5 +110,7 @@ func SignFundsTx(txHash [32]byte, tx *protocol.FundsTx, privKey *ecdsa.PrivateKe
 		copy(tx.Sig2[64-len(s.Bytes()):], s.Bytes())
 	}
 
+	client.TxSigned(txHash)
+
 	return nil
 }
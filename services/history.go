@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/protocol"
+	"log"
+	"sort"
+)
+
+func init() {
+	onReorg((*Client).rollBackTxIndex)
+}
+
+// Tx indexed at or above the first rolled back height are pending until they are verified on the new branch.
+func (client *Client) rollBackTxIndex(reorg *cstorage.Reorg) {
+	client.store.RollBackTxIndexFrom(reorg.Height)
+}
+
+// Stores a tx whose inclusion in the block was verified and indexes it at the block's height.
+func (client *Client) indexVerifiedTx(block *protocol.Block, txHash [32]byte, tx protocol.Transaction) {
+	if err := client.store.WriteIncludedTransaction(txHash, tx, block.Height, block.Hash); err != nil {
+		logger.Printf("Indexing tx %x with height %v failed: %v\n", txHash[:8], block.Height, err)
+	}
+}
+
+// Syncs the headers and prints a page of the account's tx.
+func (client *Client) ShowAccountHistory(ctx context.Context, args *args.AccountHistoryArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	if err := client.syncBlockHeaders(ctx); err != nil {
+		return err
+	}
+
+	history, err := client.GetAccountHistory(args)
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+
+	logger.Printf("My Address: %v\n", history.Address)
+	logger.Printf("Page %v, %v tx in total\n", history.Page, history.Total)
+
+	for _, tx := range history.Txs {
+		logger.Printf("Height %v: %v tx %v (%v), from %v, to %v, amount %v, fee %v\n",
+			tx.Height,
+			tx.TxType,
+			tx.TxHash,
+			tx.Status,
+			tx.From,
+			tx.To,
+			tx.Amount,
+			tx.Fee)
+	}
+
+	return nil
+}
+
+// Returns a page of the tx the address sent, received or issued, newest first. Tx not verified yet come first. The
+// account's state is computed before, which verifies and indexes the account's tx in the headers synced since the
+// last computation.
+func (client *Client) GetAccountHistory(args *args.AccountHistoryArgs) (history *AccountHistoryJson, err error) {
+	address, err := resolveAddress(&args.CheckAccountArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := client.GetAccountState(address); err != nil {
+		return nil, err
+	}
+
+	addressHash := protocol.SerializeHashContent(address)
+
+	//A tx is listed once, even if the address is both sender and recipient.
+	listed := make(map[[32]byte]bool)
+	var indexed []*cstorage.IndexedTx
+	for _, index := range []string{cstorage.SENDER_INDEX_BUCKET, cstorage.RECIPIENT_INDEX_BUCKET, cstorage.ISSUER_INDEX_BUCKET} {
+		for _, tx := range client.store.ReadAddressIndex(index, addressHash, uint32(args.From), uint32(args.To)) {
+			if !listed[tx.TxHash] {
+				listed[tx.TxHash] = true
+				indexed = append(indexed, tx)
+			}
+		}
+	}
+
+	sort.Slice(indexed, func(i, j int) bool {
+		if indexed[i].Height != indexed[j].Height {
+			return indexed[i].Height > indexed[j].Height
+		}
+
+		return bytes.Compare(indexed[i].TxHash[:], indexed[j].TxHash[:]) < 0
+	})
+
+	history = &AccountHistoryJson{
+		Address:  hex.EncodeToString(address[:]),
+		Total:    len(indexed),
+		Page:     args.Page,
+		PageSize: args.PageSize,
+		Txs:      []*AccountTxJson{},
+	}
+
+	//Pages behind the last one are empty. Tested before the offset is computed, it would overflow for huge pages.
+	if pages := (len(indexed) + args.PageSize - 1) / args.PageSize; args.Page > pages {
+		return history, nil
+	}
+
+	first := (args.Page - 1) * args.PageSize
+	for i := first; i < len(indexed) && i < first+args.PageSize; i++ {
+		if tx := client.store.ReadTransaction(indexed[i].TxHash); tx != nil {
+			history.Txs = append(history.Txs, ConvertAccountTx(indexed[i], tx))
+		}
+	}
+
+	return history, nil
+}
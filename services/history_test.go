@@ -0,0 +1,43 @@
+package services
+
+import (
+	"encoding/hex"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-miner/protocol"
+	"math"
+	"testing"
+)
+
+func TestGetAccountHistoryPages(t *testing.T) {
+	receiver := newAddress()
+	mine(t, &protocol.FundsTx{Amount: 6, TxCnt: 6, From: [32]byte{'s'}, To: protocol.SerializeHashContent(receiver)})
+
+	client := syncedClient(t)
+
+	tests := []struct {
+		page int
+		want int
+	}{
+		{1, 1},
+		{2, 0},
+		{math.MaxInt, 0},
+	}
+
+	for _, test := range tests {
+		historyArgs := &args.AccountHistoryArgs{
+			CheckAccountArgs: args.CheckAccountArgs{Address: hex.EncodeToString(receiver[:])},
+			To:               math.MaxUint32,
+			Page:             test.page,
+			PageSize:         args.DEFAULT_HISTORY_PAGE_SIZE,
+		}
+
+		history, err := client.GetAccountHistory(historyArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if history.Total != 1 || len(history.Txs) != test.want {
+			t.Errorf("Page %v lists %v of %v tx, want %v of 1", test.page, len(history.Txs), history.Total, test.want)
+		}
+	}
+}
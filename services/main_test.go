@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"github.com/way365/bazo-client/network"
+	"github.com/way365/bazo-client/network/minertest"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/crypto"
+	"github.com/way365/bazo-miner/protocol"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	//The network is initialized once, all tests talk to the same fake miner.
+	fakeMiner *minertest.Miner
+
+	//Every block after the genesis is proposed by the validator.
+	validator        *rsa.PrivateKey
+	validatorAddress = [64]byte{'v'}
+	validatorHash    = protocol.SerializeHashContent(validatorAddress)
+
+	//The chain the fake miner serves, the genesis first.
+	chain []*protocol.Block
+)
+
+func TestMain(m *testing.M) {
+	logger = log.New(io.Discard, "", 0)
+
+	//network.Init creates the performance log in the working directory.
+	dir, err := os.MkdirTemp("", "services")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Chdir(dir)
+	defer os.RemoveAll(dir)
+
+	if fakeMiner, err = minertest.NewMiner(); err != nil {
+		panic(err)
+	}
+
+	if validator, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+
+	account := &protocol.Account{Address: validatorAddress, IsStaking: true}
+	copy(account.CommitmentKey[:], validator.PublicKey.N.Bytes())
+	fakeMiner.SetAccount(account)
+
+	genesis := protocol.NewBlock([32]byte{}, 0)
+	genesis.Timestamp = time.Now().Unix() - 3600
+	genesis.Hash = genesis.HashBlock()
+	fakeMiner.AddBlock(genesis)
+	chain = append(chain, genesis)
+
+	util.Config.BootstrapIpport = fakeMiner.Addr()
+	util.Config.MinerConnections = 1
+	util.Config.BroadcastPeers = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	network.Init(ctx)
+
+	//The connection to the fake miner is registered in the background.
+	for _, err := network.BlockHeaderReq(nil); err != nil; _, err = network.BlockHeaderReq(nil) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code := m.Run()
+
+	cancel()
+	network.Wait()
+	fakeMiner.Close()
+
+	os.Exit(code)
+}
+
+// Builds a block on top of prev that includes the given tx. The tx's addresses are added to the bloom filter.
+func newBlock(t *testing.T, prev *protocol.Block, txs ...*protocol.FundsTx) *protocol.Block {
+	t.Helper()
+
+	block := protocol.NewBlock(prev.Hash, prev.Height+1)
+	block.Beneficiary = validatorHash
+	block.Timestamp = prev.Timestamp + 1
+
+	var addresses [][32]byte
+	for _, tx := range txs {
+		block.FundsTxData = append(block.FundsTxData, tx.Hash())
+		addresses = append(addresses, tx.From, tx.To)
+	}
+
+	if len(addresses) > 0 {
+		block.NrFundsTx = uint16(len(txs))
+		block.InitBloomFilter(addresses)
+		block.MerkleRoot = protocol.BuildMerkleTree(block).MerkleRoot()
+	}
+
+	proof, err := crypto.SignMessageWithRSAKey(validator, fmt.Sprint(block.Height))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block.CommitmentProof = proof
+	block.Hash = block.HashBlock()
+
+	return block
+}
+
+// A random address, so that a test only finds its own tx on the shared chain, even if it runs repeatedly.
+func newAddress() (address [64]byte) {
+	rand.Read(address[:])
+	return address
+}
+
+// Extends the served chain by a block that includes the given tx.
+func mine(t *testing.T, txs ...*protocol.FundsTx) *protocol.Block {
+	t.Helper()
+
+	block := newBlock(t, chain[len(chain)-1], txs...)
+
+	var served []protocol.Transaction
+	for _, tx := range txs {
+		served = append(served, tx)
+	}
+
+	fakeMiner.AddBlock(block, served...)
+	chain = append(chain, block)
+
+	return block
+}
+
+// Extends the served chain by a block that includes the given ConfigTx.
+func mineConfigTx(t *testing.T, configTx *protocol.ConfigTx) *protocol.Block {
+	t.Helper()
+
+	block := newBlock(t, chain[len(chain)-1])
+	block.NrConfigTx = 1
+	block.ConfigTxData = [][32]byte{configTx.Hash()}
+	block.MerkleRoot = protocol.BuildMerkleTree(block).MerkleRoot()
+	block.Hash = block.HashBlock()
+
+	fakeMiner.AddBlock(block, configTx)
+	chain = append(chain, block)
+
+	return block
+}
@@ -3,15 +3,12 @@ package services
 import (
 	"errors"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/network"
-	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/crypto"
-	"github.com/way365/bazo-miner/p2p"
 	"github.com/way365/bazo-miner/protocol"
 	"log"
 )
 
-func ConfigureNetwork(args *args.NetworkArgs, logger *log.Logger) error {
+func (client *Client) ConfigureNetwork(args *args.NetworkArgs, logger *log.Logger) error {
 	err := args.ValidateInput()
 	if err != nil {
 		return err
@@ -38,11 +35,8 @@ func ConfigureNetwork(args *args.NetworkArgs, logger *log.Logger) error {
 		return errors.New("transaction encoding failed")
 	}
 
-	if err := network.SendTx(util.Config.BootstrapIpport, tx, p2p.CONFIGTX_BRDCST); err != nil {
-		logger.Printf("%v\n", err)
+	if _, err := client.SubmitTx(tx.Hash(), tx); err != nil {
 		return err
-	} else {
-		logger.Printf("Transaction successfully sent to network:\nTxHash: %x%v", tx.Hash(), tx)
 	}
 
 	return nil
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/miner"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"log"
+)
+
+func init() {
+	onReorg((*Client).dropParameters)
+}
+
+// The timeline entries from the first rolled back height on were built from rolled back blocks.
+func (client *Client) dropParameters(reorg *cstorage.Reorg) {
+	client.store.DeleteParametersFrom(reorg.Height)
+}
+
+// Applies the ConfigTx of every block up to the last header to the parameter timeline. Processing stops at the
+// first block whose ConfigTx cannot be fetched or verified, the next call continues from there.
+func (client *Client) syncParameters() {
+	tip := client.lastHeader()
+	if tip == nil {
+		return
+	}
+	for from := client.store.ReadParametersSyncHeight(); from <= tip.Height; from += HEADER_BATCH_SIZE {
+		to := from + HEADER_BATCH_SIZE - 1
+		if to > tip.Height {
+			to = tip.Height
+		}
+
+		for _, blockHeader := range client.store.ReadBlockHeadersByRange(from, to) {
+			if blockHeader.NrConfigTx == 0 {
+				continue
+			}
+
+			var parameters miner.Parameters
+			if blockHeader.Height > 0 {
+				parameters = client.store.ReadParameters(blockHeader.Height - 1)
+			} else {
+				parameters = miner.NewDefaultParameters()
+			}
+
+			if err := client.applyConfigTx(blockHeader, &parameters); err != nil {
+				logger.Printf("Syncing parameters of block %x with height %v failed: %v\n", blockHeader.Hash[:8], blockHeader.Height, err)
+
+				//The blocks below carry no ConfigTx, the timeline is complete up to this block.
+				client.store.WriteParametersSyncHeight(blockHeader.Height)
+				return
+			}
+
+			client.store.WriteParameters(blockHeader.Height, parameters)
+			client.store.WriteParametersSyncHeight(blockHeader.Height + 1)
+
+			logger.Printf("Parameters changed at height %v\n", blockHeader.Height)
+		}
+
+		client.store.WriteParametersSyncHeight(to + 1)
+	}
+}
+
+// Fetches the block's ConfigTx, verifies their inclusion and applies them to parameters.
+func (client *Client) applyConfigTx(blockHeader *protocol.Block, parameters *miner.Parameters) error {
+	blocks, err := getRelevantBlocks([]*protocol.Block{blockHeader})
+	if err != nil {
+		return err
+	}
+
+	block := blocks[0]
+	for _, txHash := range block.ConfigTxData {
+		txI, err := fetchTx(p2p.CONFIGTX_REQ, txHash)
+		if err != nil {
+			return err
+		}
+
+		tx := txI.(protocol.Transaction)
+		configTx := txI.(*protocol.ConfigTx)
+
+		if err := client.validateTx(block, tx, txHash); err != nil {
+			return err
+		}
+
+		configTxSlice := []*protocol.ConfigTx{configTx}
+		miner.CheckAndChangeParameters(parameters, &configTxSlice)
+	}
+
+	return nil
+}
+
+func (client *Client) ShowParameters(ctx context.Context, args *args.ParametersArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	if err := client.syncBlockHeaders(ctx); err != nil {
+		return err
+	}
+
+	last := client.lastHeader()
+	if last == nil {
+		return errors.New("no block headers synced")
+	}
+
+	height := last.Height
+	if args.Height >= 0 {
+		height = uint32(args.Height)
+	}
+
+	if height > last.Height {
+		return errors.New(fmt.Sprintf("height %v is above the synced chain's height %v", height, last.Height))
+	}
+
+	if height >= client.store.ReadParametersSyncHeight() {
+		logger.Printf("Parameters are synced up to height %v only\n", client.store.ReadParametersSyncHeight())
+	}
+
+	parameters := client.store.ReadParameters(height)
+
+	logger.Printf("Parameters as of height %v:\nBlock size: %v bytes\nDifficulty interval: %v blocks\nMinimum fee: %v\nBlock interval: %v seconds\nBlock reward: %v\n",
+		height,
+		parameters.BlockSize,
+		parameters.DiffInterval,
+		parameters.FeeMinimum,
+		parameters.BlockInterval,
+		parameters.BlockReward)
+
+	return nil
+}
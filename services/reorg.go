@@ -0,0 +1,21 @@
+package services
+
+import (
+	"github.com/way365/bazo-client/cstorage"
+)
+
+var (
+	reorgHandlers []func(client *Client, reorg *cstorage.Reorg)
+)
+
+// Registers a handler that is called after the client's synced chain switched to a competing branch. Everything
+// derived from the headers in reorg.RolledBack must be dropped by the handler.
+func onReorg(handler func(client *Client, reorg *cstorage.Reorg)) {
+	reorgHandlers = append(reorgHandlers, handler)
+}
+
+func (client *Client) notifyReorg(reorg *cstorage.Reorg) {
+	for _, handler := range reorgHandlers {
+		handler(client, reorg)
+	}
+}
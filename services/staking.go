@@ -4,15 +4,12 @@ import (
 	"crypto/rsa"
 	"errors"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/network"
-	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/crypto"
-	"github.com/way365/bazo-miner/p2p"
 	"github.com/way365/bazo-miner/protocol"
 	"log"
 )
 
-func ToggleStaking(args *args.StakingArgs, logger *log.Logger) error {
+func (client *Client) ToggleStaking(args *args.StakingArgs, logger *log.Logger) error {
 	err := args.ValidateInput()
 	if err != nil {
 		return err
@@ -51,11 +48,10 @@ func ToggleStaking(args *args.StakingArgs, logger *log.Logger) error {
 		return errors.New("transaction encoding failed")
 	}
 
-	if err := network.SendTx(util.Config.BootstrapIpport, tx, p2p.STAKETX_BRDCST); err != nil {
-		logger.Printf("%v\n", err)
+	client.store.WriteTransaction(tx.Hash(), tx)
+
+	if _, err := client.SubmitTx(tx.Hash(), tx); err != nil {
 		return err
-	} else {
-		logger.Printf("Transaction successfully sent to network:\nTxHash: %x%v", tx.Hash(), tx)
 	}
 
 	return nil
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"encoding/hex"
+	"github.com/way365/bazo-client/cstorage"
+)
+
+type StakingEventJson struct {
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockHash"`
+	TxHash    string `json:"txHash"`
+	IsStaking bool   `json:"isStaking"`
+}
+
+func ConvertStakingEvent(event cstorage.StakingEvent) (stakingEventJson *StakingEventJson) {
+	return &StakingEventJson{
+		event.Height,
+		hex.EncodeToString(event.BlockHash[:]),
+		hex.EncodeToString(event.TxHash[:]),
+		event.IsStaking,
+	}
+}
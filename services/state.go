@@ -1,194 +1,385 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/way365/bazo-client/cstorage"
 	"github.com/way365/bazo-client/network"
+	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/miner"
 	"github.com/way365/bazo-miner/p2p"
 	"github.com/way365/bazo-miner/protocol"
+	"time"
 )
 
 var (
-	//All blockheaders of the whole chain
-	blockHeaders []*protocol.Block
-
-	activeParameters miner.Parameters
-
 	UnsignedAccTx    = make(map[[32]byte]*protocol.AccTx)
 	UnsignedConfigTx = make(map[[32]byte]*protocol.ConfigTx)
 	UnsignedFundsTx  = make(map[[32]byte]*protocol.FundsTx)
 )
 
-// Update allBlockHeaders to the latest header. Start listening to broadcasted headers after.
-func Sync() {
-	loadBlockHeaders()
-	go incomingBlockHeaders()
+// Number of headers read from cstorage at once when scanning the chain.
+const HEADER_BATCH_SIZE = 1000
+
+// Update the header chain to the latest header. Start listening to broadcasted headers after, until ctx is done.
+func (client *Client) Sync(ctx context.Context) error {
+	if err := client.loadBlockHeaders(); err != nil {
+		return err
+	}
+
+	client.syncParameters()
+
+	client.running.Add(3)
+	go client.watchService(ctx)
+	client.triggerWatchList()
+	go client.txStatusService(ctx)
+	client.triggerTxStatuses()
+	go client.incomingBlockHeaders(ctx)
+
+	return nil
+}
+
+// Blocks until the goroutines started by Sync returned after its context is done.
+func (client *Client) Wait() {
+	client.running.Wait()
 }
 
-func loadBlockHeaders() {
-	var last *protocol.Block
+func (client *Client) loadBlockHeaders() error {
+	if last := client.store.ReadLastBlockHeader(); last != nil {
+		//Complete the height index in case the DB was written before the index existed.
+		if err := client.store.WriteBlockHeightIndex(last); err != nil {
+			return err
+		}
+
+		client.setLastHeader(last)
 
-	//youngest = fetchBlockHeader(nil)
-	if last = cstorage.ReadLastBlockHeader(); last != nil {
-		var loaded []*protocol.Block
-		loaded = loadDB(last, [32]byte{}, loaded)
-		blockHeaders = append(blockHeaders, loaded...)
+		logger.Printf("Header %x with height %v loaded from DB\n",
+			last.Hash[:8],
+			last.Height)
 	}
 
 	//The client is up to date with the network and can start listening for incoming headers.
 	network.Uptodate = true
+
+	return nil
 }
 
-func incomingBlockHeaders() {
-	for {
-		blockHeaderIn := <-network.BlockHeaderIn
+// Loads the synced chain from the DB and catches up with the latest header of the network. Used by one-shot
+// commands that do not listen to broadcasted headers. Returns ctx.Err() if ctx is done before the chain is synced.
+func (client *Client) syncBlockHeaders(ctx context.Context) error {
+	if err := client.loadBlockHeaders(); err != nil {
+		return err
+	}
+
+	if latest := fetchBlockHeader(ctx, nil); latest != nil {
+		network.Uptodate = false
+		client.switchBranch(ctx, latest)
+		network.Uptodate = true
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		var last *protocol.Block
-		var lastHash [32]byte
+	client.syncParameters()
 
-		//Get the last header in the blockHeaders array. Its hash is relevant for appending the incoming header or the abort condition for recursive header fetching.
-		if len(blockHeaders) > 0 {
-			last = blockHeaders[len(blockHeaders)-1]
-			lastHash = last.Hash
-		} else {
-			lastHash = [32]byte{}
+	return nil
+}
+
+func (client *Client) incomingBlockHeaders(ctx context.Context) {
+	defer client.running.Done()
+
+	for {
+		var received *network.ReceivedBlockHeader
+		select {
+		case received = <-network.BlockHeaderIn:
+		case <-ctx.Done():
+			return
 		}
 
-		//The incoming block header is already the last saved in the array.
-		if blockHeaderIn.Hash == lastHash {
+		blockHeaderIn := received.Header
+		last := client.lastHeader()
+		if blockHeaderIn == nil {
+			network.FlagPeer(received.Peer, errors.New("broadcasted header could not be decoded"))
 			continue
 		}
 
-		//The client is out of sync. Header cannot be appended to the array. The client must sync first.
-		if last == nil || blockHeaderIn.PrevHash != lastHash {
-			//Set the uptodate flag to false in order to avoid listening to new incoming block headers.
-			network.Uptodate = false
+		//The incoming block header is already the last saved header.
+		if last != nil && blockHeaderIn.Hash == last.Hash {
+			continue
+		}
 
-			var loaded []*protocol.Block
+		//The incoming header extends the synced chain.
+		if last != nil && blockHeaderIn.PrevHash == last.Hash {
+			if err := validateBlockHeaderWithRetry(ctx, blockHeaderIn, last, received.Peer); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 
-			if last == nil || len(blockHeaders) <= 100 {
-				blockHeaders = []*protocol.Block{}
-				loaded = loadNetwork(blockHeaderIn, [32]byte{}, loaded)
-			} else {
-				//Remove the last 100 headers. This is precaution if the array contains rolled back blocks.
-				blockHeaders = blockHeaders[:len(blockHeaders)-100]
-				loaded = loadNetwork(blockHeaderIn, blockHeaders[len(blockHeaders)-1].Hash, loaded)
+				rejectBlockHeader(err)
+				continue
 			}
 
-			blockHeaders = append(blockHeaders, loaded...)
-			cstorage.WriteLastBlockHeader(blockHeaders[len(blockHeaders)-1])
+			client.saveAndLogBlockHeader(blockHeaderIn)
 
-			network.Uptodate = true
-		} else if blockHeaderIn.PrevHash == lastHash {
-			saveAndLogBlockHeader(blockHeaderIn)
+			client.setLastHeader(blockHeaderIn)
+			client.store.WriteLastBlockHeader(blockHeaderIn)
 
-			blockHeaders = append(blockHeaders, blockHeaderIn)
-			cstorage.WriteLastBlockHeader(blockHeaderIn)
+			client.syncParameters()
+			client.triggerWatchList()
+			client.triggerTxStatuses()
+
+			continue
 		}
+
+		//The incoming header belongs to a competing branch or the client is out of sync.
+		//Set the uptodate flag to false in order to avoid listening to new incoming block headers.
+		network.Uptodate = false
+		client.switchBranch(ctx, received)
+		if ctx.Err() != nil {
+			return
+		}
+
+		client.syncParameters()
+		client.triggerWatchList()
+		client.triggerTxStatuses()
+		network.Uptodate = true
 	}
 }
 
-func fetchBlockHeader(blockHash []byte) (blockHeader *protocol.Block) {
-	var errormsg string
-	if blockHash != nil {
-		errormsg = fmt.Sprintf("Loading header %x failed: ", blockHash[:8])
-	}
+// Loads the branch of the given tip down to the common ancestor with the synced chain. The branch with the greater
+// height wins, on a tie the synced chain is kept because it was seen first. Bazo headers carry no accumulated work,
+// hence height is the only weight both branches can be compared by. Gives up on the branch if ctx is done before it
+// is loaded.
+func (client *Client) switchBranch(ctx context.Context, received *network.ReceivedBlockHeader) {
+	tip := received.Header
 
-	err := network.BlockHeaderReq(blockHash[:])
+	ancestor, err := client.loadBranch(ctx, received)
 	if err != nil {
-		logger.Println(errormsg + err.Error())
-		return nil
+		if ctx.Err() == nil {
+			rejectBlockHeader(err)
+		}
+
+		return
 	}
 
-	blockHeaderI, err := network.Fetch(network.BlockHeaderChan)
+	if last := client.lastHeader(); last != nil && tip.Height <= last.Height {
+		logger.Printf("Branch with tip %x and height %v is not longer than the synced chain with height %v. Keeping the synced chain.\n",
+			tip.Hash[:8],
+			tip.Height,
+			last.Height)
+		return
+	}
+
+	reorg, err := client.store.WriteChainTip(tip)
 	if err != nil {
-		logger.Println(errormsg + err.Error())
-		return nil
+		logger.Printf("Switching to branch with tip %x failed: %v\n", tip.Hash[:8], err)
+		return
 	}
 
-	blockHeader = blockHeaderI.(*protocol.Block)
+	client.setLastHeader(tip)
 
-	logger.Printf("Fetched header with height %v\n", blockHeader.Height)
+	if ancestor != nil {
+		logger.Printf("Synced chain switched to tip %x with height %v, common ancestor %x with height %v\n",
+			tip.Hash[:8],
+			tip.Height,
+			ancestor.Hash[:8],
+			ancestor.Height)
+	}
 
-	return blockHeader
+	if reorg != nil {
+		logger.Println(reorg.String())
+		client.notifyReorg(reorg)
+	}
 }
 
-func loadDB(last *protocol.Block, abort [32]byte, loaded []*protocol.Block) []*protocol.Block {
-	var ancestor *protocol.Block
+// Walks back from the given header until a header of the synced chain or the genesis is reached. Headers are read
+// from cstorage if a previous sync already stored them, otherwise they are fetched from the network and validated
+// before they are saved. Every header is saved as soon as it is loaded, hence only one header is held in memory at
+// a time. Returns the common ancestor or nil if the branches share no header. A predecessor no miner delivers is
+// fetched again after a backoff until ctx is done, ctx.Err() is returned then. The same holds for a header that
+// cannot be validated because its block or its beneficiary's commitment key cannot be fetched.
+func (client *Client) loadBranch(ctx context.Context, received *network.ReceivedBlockHeader) (ancestor *protocol.Block, err error) {
+	block, peer := received.Header, received.Peer
 
-	if last.PrevHash != abort {
-		if ancestor = cstorage.ReadBlockHeader(last.PrevHash); ancestor == nil {
-			logger.Fatal()
+	for {
+		if client.store.ReadBlockHashByHeight(block.Height) == block.Hash {
+			return block, nil
 		}
 
-		loaded = loadDB(ancestor, abort, loaded)
-	}
+		if client.store.ReadBlockHeader(block.Hash) == nil {
+			if err := validateBlockHeaderWithRetry(ctx, block, nil, peer); err != nil {
+				return nil, err
+			}
+
+			client.store.WriteBranchBlockHeader(block)
+			logger.Printf("Header %x with height %v loaded from network\n",
+				block.Hash[:8],
+				block.Height)
+		}
+
+		if block.PrevHash == [32]byte{} {
+			return nil, nil
+		}
+
+		//Headers read from the DB were validated when they were saved.
+		prevBlock, prevPeer := client.store.ReadBlockHeader(block.PrevHash), ""
+		if prevBlock == nil {
+			var queryHash [2 * miner.BLOCKHASH_SIZE]byte
+			copy(queryHash[:32], block.PrevHash[:])
+			copy(queryHash[32:], block.PrevHashWithoutTx[:])
+
+			backoff := util.RECONNECT_BACKOFF_MIN * time.Second
+			fetched := fetchBlockHeader(ctx, queryHash[:])
+			for fetched == nil {
+				logger.Printf("Try to fetch header %x with height %v again in %v\n", block.PrevHash[:8], block.Height-1, backoff)
+
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+
+				if backoff *= 2; backoff > util.FETCH_RETRY_MAX*time.Second {
+					backoff = util.FETCH_RETRY_MAX * time.Second
+				}
 
-	logger.Printf("Header %x with height %v loaded from DB\n",
-		last.Hash[:8],
-		last.Height)
+				fetched = fetchBlockHeader(ctx, queryHash[:])
+			}
+
+			prevBlock, prevPeer = fetched.Header, fetched.Peer
+		}
+
+		//A fetched predecessor that does not link is blamed on the peer that sent it.
+		blamed := prevPeer
+		if blamed == "" {
+			blamed = peer
+		}
 
-	loaded = append(loaded, last)
+		if err := validateBlockHeaderLink(block, prevBlock, blamed); err != nil {
+			return nil, err
+		}
 
-	return loaded
+		block, peer = prevBlock, prevPeer
+	}
 }
 
-func loadNetwork(block *protocol.Block, abort [32]byte, loaded []*protocol.Block) []*protocol.Block {
-	var queryHash [2 * miner.BLOCKHASH_SIZE]byte
-	copy(queryHash[:32], block.PrevHash[:])
-	copy(queryHash[32:], block.PrevHashWithoutTx[:])
+// Validates a header like validateBlockHeader. A header whose block or beneficiary's commitment key cannot be fetched
+// is not rejected, it is validated again after a backoff until ctx is done, ctx.Err() is returned then.
+func validateBlockHeaderWithRetry(ctx context.Context, header *protocol.Block, prev *protocol.Block, peer string) error {
+	backoff := util.RECONNECT_BACKOFF_MIN * time.Second
+	for {
+		err := validateBlockHeader(header, prev, peer)
+		if !errors.Is(err, ErrHeaderBlock) && !errors.Is(err, ErrCommitmentKey) {
+			return err
+		}
+
+		logger.Printf("%v, validating again in %v\n", err, backoff)
 
-	var prevBlock *protocol.Block
-	if prevBlock = fetchBlockHeader(queryHash[:]); prevBlock == nil {
-		for prevBlock == nil {
-			logger.Printf("Try to fetch header %x with height %v again\n", block.Hash[:8], block.Height)
-			prevBlock = fetchBlockHeader(queryHash[:])
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if backoff *= 2; backoff > util.FETCH_RETRY_MAX*time.Second {
+			backoff = util.FETCH_RETRY_MAX * time.Second
 		}
 	}
+}
 
-	if block.PrevHash != abort {
-		loaded = loadNetwork(prevBlock, abort, loaded)
+// Fetches the header with the given hash, or the latest header if blockHash is nil. Returns nil if the header could
+// not be fetched or ctx is done.
+func fetchBlockHeader(ctx context.Context, blockHash []byte) (received *network.ReceivedBlockHeader) {
+	if ctx.Err() != nil {
+		return nil
 	}
 
-	saveAndLogBlockHeader(block)
+	var errormsg string
+	if blockHash != nil {
+		errormsg = fmt.Sprintf("Loading header %x failed: ", blockHash[:8])
+	}
 
-	loaded = append(loaded, block)
+	future, err := network.BlockHeaderReq(blockHash[:])
+	if err != nil {
+		logger.Println(errormsg + err.Error())
+		return nil
+	}
+
+	blockHeaderI, err := future.Get()
+	if err != nil {
+		logger.Println(errormsg + err.Error())
+		return nil
+	}
 
-	return loaded
+	received = blockHeaderI.(*network.ReceivedBlockHeader)
+	if received.Header == nil {
+		logger.Println(errormsg + "header could not be decoded")
+		return nil
+	}
+
+	logger.Printf("Fetched header with height %v\n", received.Header.Height)
+
+	return received
 }
 
-func saveAndLogBlockHeader(blockHeader *protocol.Block) {
-	cstorage.WriteBlockHeader(blockHeader)
+func (client *Client) saveAndLogBlockHeader(blockHeader *protocol.Block) {
+	client.store.WriteBlockHeader(blockHeader)
 	logger.Printf("Header %x with height %v loaded from network\n",
 		blockHeader.Hash[:8],
 		blockHeader.Height)
 }
 
-func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
+// Computes the verified state from the block at height from on. Transactions not yet included in a block are
+// added by getNonVerifiedState.
+func (client *Client) getState(state *AccountState, from uint32) (err error) {
+	acc := state.Account
+	lastTenTx := state.LastTenTx
+
 	pubKeyHash := protocol.SerializeHashContent(acc.Address)
 	//Get blocks if the Acc address:
 	//* got issued as an Acc
 	//* sent funds
 	//* received funds
 	//* is block's beneficiary
-	//* nr of configTx in block is > 0 (in order to maintain params in light-client)
+	//* is beneficiary of a block with configTx (in order to collect their fees)
+
+	if state.tip == nil {
+		return nil
+	}
+
+	relevantHeadersBeneficiary, relevantHeadersConfigBF, headersScanned := client.getRelevantBlockHeaders(pubKeyHash, from, state.tip.Height)
+	state.HeadersScanned = headersScanned
+
+	//The block reward is read from the parameter timeline, which has to reach the last block the account proposed.
+	if len(relevantHeadersBeneficiary) > 0 {
+		last := relevantHeadersBeneficiary[len(relevantHeadersBeneficiary)-1]
+		if client.store.ReadParametersSyncHeight() <= last.Height {
+			client.syncParameters()
+		}
 
-	relevantHeadersBeneficiary, relevantHeadersConfigBF := getRelevantBlockHeaders(pubKeyHash)
+		if client.store.ReadParametersSyncHeight() <= last.Height {
+			return errors.New(fmt.Sprintf("Parameters are not synced up to height %v, the block rewards cannot be computed", last.Height))
+		}
+	}
 
-	acc.Balance += activeParameters.BlockReward * uint64(len(relevantHeadersBeneficiary))
+	for _, blockHeader := range relevantHeadersBeneficiary {
+		acc.Balance += client.store.ReadParameters(blockHeader.Height).BlockReward
+	}
 
 	relevantBlocks, err := getRelevantBlocks(relevantHeadersConfigBF)
+	if err != nil {
+		return err
+	}
+
 	for _, block := range relevantBlocks {
 		if block != nil {
+			state.BlocksScanned++
+
 			//Balance funds and collect fee
 			for _, txHash := range block.FundsTxData {
-				err := network.TxReq(p2p.FUNDSTX_REQ, txHash)
-				if err != nil {
-					return err
-				}
-
-				txI, err := network.Fetch(network.FundsTxChan)
+				txI, err := fetchTx(p2p.FUNDSTX_REQ, txHash)
 				if err != nil {
 					return err
 				}
@@ -198,10 +389,13 @@ func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
 
 				if fundsTx.From == pubKeyHash || fundsTx.To == pubKeyHash || block.Beneficiary == pubKeyHash {
 					//Validate tx
-					if err := validateTx(block, tx, txHash); err != nil {
+					if err := client.validateTx(block, tx, txHash); err != nil {
 						return err
 					}
 
+					state.TxVerified++
+					client.indexVerifiedTx(block, txHash, tx)
+
 					if fundsTx.From == pubKeyHash {
 						//If Acc is no root, balance funds
 						if !acc.IsRoot {
@@ -216,6 +410,7 @@ func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
 						acc.Balance += fundsTx.Amount
 
 						put(lastTenTx, ConvertFundsTx(fundsTx, "verified"))
+						state.receiveVerified(fundsTx)
 					}
 
 					if block.Beneficiary == pubKeyHash {
@@ -225,44 +420,42 @@ func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
 			}
 
 			//Check if Account was issued and collect fee
-			//for _, txHash := range block.AccTxData {
-			//	err := network.TxReq(p2p.ACCTX_REQ, txHash)
-			//	if err != nil {
-			//		return err
-			//	}
-			//
-			//	txI, err := network.Fetch(network.AccTxChan)
-			//	if err != nil {
-			//		return err
-			//	}
-			//
-			//	tx := txI.(protocol.Transaction)
-			//	accTx := txI.(*protocol.AccTx)
-			//
-			//	if accTx.PubKey == acc.Address || block.Beneficiary == pubKeyHash {
-			//		//Validate tx
-			//		if err := validateTx(block, tx, txHash); err != nil {
-			//			return err
-			//		}
-			//
-			//		if accTx.PubKey == acc.Address {
-			//			acc.IsCreated = true
-			//		}
-			//
-			//		if block.Beneficiary == pubKeyHash {
-			//			acc.Balance += accTx.Fee
-			//		}
-			//	}
-			//}
-
-			//Update config parameters and collect fee
-			for _, txHash := range block.ConfigTxData {
-				err := network.TxReq(p2p.CONFIGTX_REQ, txHash)
+			for _, txHash := range block.AccTxData {
+				txI, err := fetchTx(p2p.ACCTX_REQ, txHash)
 				if err != nil {
 					return err
 				}
 
-				txI, err := network.Fetch(network.ConfigTxChan)
+				tx := txI.(protocol.Transaction)
+				accTx := txI.(*protocol.AccTx)
+
+				if accTx.PubKey == acc.Address || block.Beneficiary == pubKeyHash {
+					//Validate tx
+					if err := client.validateTx(block, tx, txHash); err != nil {
+						return err
+					}
+
+					state.TxVerified++
+					client.indexVerifiedTx(block, txHash, tx)
+
+					if accTx.PubKey == acc.Address {
+						acc.IsCreated = true
+						acc.IssuanceHeight = block.Height
+					}
+
+					if block.Beneficiary == pubKeyHash {
+						acc.Balance += accTx.Fee
+					}
+				}
+			}
+
+			//Collect fee, the parameters are maintained by syncParameters
+			for _, txHash := range block.ConfigTxData {
+				if block.Beneficiary != pubKeyHash {
+					break
+				}
+
+				txI, err := fetchTx(p2p.CONFIGTX_REQ, txHash)
 				if err != nil {
 					return err
 				}
@@ -270,25 +463,65 @@ func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
 				tx := txI.(protocol.Transaction)
 				configTx := txI.(*protocol.ConfigTx)
 
-				configTxSlice := []*protocol.ConfigTx{configTx}
+				//Validate tx
+				if err := client.validateTx(block, tx, txHash); err != nil {
+					return err
+				}
+
+				state.TxVerified++
+				client.indexVerifiedTx(block, txHash, tx)
+
+				acc.Balance += configTx.Fee
+			}
+
+			//Update staking status and collect fee
+			for _, txHash := range block.StakeTxData {
+				txI, err := fetchTx(p2p.STAKETX_REQ, txHash)
+				if err != nil {
+					return err
+				}
+
+				tx := txI.(protocol.Transaction)
+				stakeTx := txI.(*protocol.StakeTx)
 
-				if block.Beneficiary == pubKeyHash {
+				if stakeTx.Account == pubKeyHash || block.Beneficiary == pubKeyHash {
 					//Validate tx
-					if err := validateTx(block, tx, txHash); err != nil {
+					if err := client.validateTx(block, tx, txHash); err != nil {
 						return err
 					}
 
-					acc.Balance += configTx.Fee
-				}
+					state.TxVerified++
+					client.indexVerifiedTx(block, txHash, tx)
 
-				miner.CheckAndChangeParameters(&activeParameters, &configTxSlice)
-			}
+					if stakeTx.Account == pubKeyHash {
+						if !acc.IsRoot {
+							acc.Balance -= stakeTx.Fee
+						}
 
-			//TODO stakeTx
+						state.stake(cstorage.StakingEvent{
+							Height:    block.Height,
+							BlockHash: block.Hash,
+							TxHash:    txHash,
+							IsStaking: stakeTx.IsStaking,
+						})
+					}
+
+					if block.Beneficiary == pubKeyHash {
+						acc.Balance += stakeTx.Fee
+					}
+				}
+			}
 
 		}
 	}
 
+	return nil
+}
+
+func getNonVerifiedState(state *AccountState) {
+	acc := state.Account
+	lastTenTx := state.LastTenTx
+
 	addressHash := protocol.SerializeHashContent(acc.Address)
 	for _, tx := range network.NonVerifiedTxReq(addressHash) {
 		if tx.To == addressHash {
@@ -298,6 +531,4 @@ func getState(acc *Account, lastTenTx []*FundsTxJson) (err error) {
 			acc.TxCnt++
 		}
 	}
-
-	return nil
 }
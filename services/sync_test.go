@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Returns a client with an empty store that synced the chain the fake miner serves.
+func syncedClient(t *testing.T) *Client {
+	t.Helper()
+
+	client := NewClient(cstorage.NewMemoryStore())
+	if err := client.syncBlockHeaders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// Fails if the synced chain of the client is not the chain the fake miner serves.
+func assertSynced(t *testing.T, client *Client) {
+	t.Helper()
+
+	last := client.store.ReadLastBlockHeader()
+	if tip := chain[len(chain)-1]; last == nil || last.Hash != tip.Hash {
+		t.Fatalf("Last header = %v, want %x with height %v", last, tip.Hash[:8], tip.Height)
+	}
+
+	for _, block := range chain {
+		if got := client.store.ReadBlockHashByHeight(block.Height); got != block.Hash {
+			t.Errorf("Header at height %v = %x, want %x", block.Height, got[:8], block.Hash[:8])
+		}
+	}
+}
+
+// Polls until condition holds or the timeout passes.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out")
+		}
+	}
+}
+
+func TestSyncBlockHeaders(t *testing.T) {
+	mine(t)
+	mine(t)
+
+	client := syncedClient(t)
+	assertSynced(t, client)
+
+	//Syncing again only fetches the new header.
+	mine(t)
+	if err := client.syncBlockHeaders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSynced(t, client)
+}
+
+func TestSyncBlockHeadersSwitchesBranch(t *testing.T) {
+	mine(t)
+	client := syncedClient(t)
+
+	//The fake miner switches to a longer branch that forks below its tip. The first block of the branch is proposed
+	//a second later than the tip it competes with.
+	old, fork := chain[len(chain)-1], chain[len(chain)-2]
+	first := newBlock(t, fork)
+	first.Timestamp++
+	first.Hash = first.HashBlock()
+	second := newBlock(t, first)
+	fakeMiner.AddBranchBlock(first)
+	fakeMiner.AddBlock(second)
+	chain = append(chain[:len(chain)-1], first, second)
+
+	if err := client.syncBlockHeaders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSynced(t, client)
+
+	want := &cstorage.Reorg{
+		OldTip:     old.Hash,
+		NewTip:     second.Hash,
+		Height:     first.Height,
+		Depth:      1,
+		RolledBack: [][32]byte{old.Hash},
+	}
+
+	if reorgs := client.store.ReadReorgs(); len(reorgs) != 1 || !reflect.DeepEqual(reorgs[0], want) {
+		t.Errorf("ReadReorgs() = %v, want [%v]", reorgs, want)
+	}
+}
+
+func TestSyncFollowsBroadcastedHeaders(t *testing.T) {
+	client := syncedClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		client.Wait()
+	}()
+
+	if err := client.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	block := newBlock(t, chain[len(chain)-1])
+	chain = append(chain, block)
+	fakeMiner.BroadcastBlockHeader(block)
+
+	waitFor(t, func() bool {
+		last := client.store.ReadLastBlockHeader()
+		return last != nil && last.Hash == block.Hash
+	})
+
+	assertSynced(t, client)
+}
+
+func TestGetAccountState(t *testing.T) {
+	receiver := newAddress()
+	tx := &protocol.FundsTx{Amount: 5, Fee: 1, TxCnt: 1, From: [32]byte{'s'}, To: protocol.SerializeHashContent(receiver)}
+	block := mine(t, tx)
+
+	client := syncedClient(t)
+
+	state, err := client.GetAccountState(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Bloom filters of other blocks may match the address as well.
+	if state.Account.Balance != tx.Amount || state.BlocksScanned < 1 || state.TxVerified != 1 {
+		t.Errorf("Balance %v from %v blocks and %v tx, want %v from at least 1 block and 1 tx",
+			state.Account.Balance, state.BlocksScanned, state.TxVerified, tx.Amount)
+	}
+
+	txHash := tx.Hash()
+	if received := state.LastTenTx[9]; received == nil || received.Hash != hex.EncodeToString(txHash[:]) || received.Status != "verified" {
+		t.Errorf("Last received tx = %+v, want %x verified", received, txHash[:8])
+	}
+
+	indexed := client.store.ReadAddressIndex(cstorage.RECIPIENT_INDEX_BUCKET, tx.To, 0, cstorage.PENDING_HEIGHT)
+	if want := []*cstorage.IndexedTx{{TxHash: txHash, Height: block.Height, BlockHash: block.Hash}}; !reflect.DeepEqual(indexed, want) {
+		t.Errorf("Recipient index = %v, want %v", indexed, want)
+	}
+}
+
+func TestGetAccountStateRejectsForgedMerklePath(t *testing.T) {
+	receiver := newAddress()
+	mine(t, &protocol.FundsTx{Amount: 5, From: [32]byte{'s'}, To: protocol.SerializeHashContent(receiver)})
+
+	fakeMiner.Handle(p2p.INTERMEDIATE_NODES_REQ, func(payload []byte) (uint8, []byte) {
+		return p2p.INTERMEDIATE_NODES_RES, make([]byte, 64)
+	})
+	defer fakeMiner.Handle(p2p.INTERMEDIATE_NODES_REQ, nil)
+
+	client := syncedClient(t)
+
+	if _, err := client.GetAccountState(receiver); !errors.Is(err, ErrMerkleProof) {
+		t.Errorf("GetAccountState() = %v, want %v", err, ErrMerkleProof)
+	}
+}
+
+func TestSyncParameters(t *testing.T) {
+	configTx := &protocol.ConfigTx{Id: protocol.BLOCK_REWARD_ID, Payload: uint64(len(chain)), TxCnt: uint8(len(chain))}
+	block := mineConfigTx(t, configTx)
+
+	client := syncedClient(t)
+
+	//The header is saved as the miner sent it, without the Merkle root the ConfigTx is verified against.
+	if header := client.store.ReadBlockHeader(block.Hash); header == nil || header.MerkleRoot != [32]byte{} {
+		t.Fatalf("Header at height %v = %v, want the header without the Merkle root", block.Height, header)
+	}
+
+	if synced := client.store.ReadParametersSyncHeight(); synced != chain[len(chain)-1].Height+1 {
+		t.Fatalf("Parameters synced up to height %v, want %v", synced, chain[len(chain)-1].Height+1)
+	}
+
+	if parameters := client.store.ReadParameters(block.Height); parameters.BlockReward != configTx.Payload {
+		t.Errorf("Block reward at height %v = %v, want %v", block.Height, parameters.BlockReward, configTx.Payload)
+	}
+}
+
+func TestGetAccountStateRequiresSyncedParameters(t *testing.T) {
+	//The ConfigTx of the block cannot be validated while the fake miner sends forged Merkle paths.
+	block := mineConfigTx(t, &protocol.ConfigTx{Id: 0, TxCnt: uint8(len(chain))})
+
+	fakeMiner.Handle(p2p.INTERMEDIATE_NODES_REQ, func(payload []byte) (uint8, []byte) {
+		return p2p.INTERMEDIATE_NODES_RES, make([]byte, 64)
+	})
+	defer fakeMiner.Handle(p2p.INTERMEDIATE_NODES_REQ, nil)
+
+	//On repeated runs, the block added by the first run is the first one that cannot be loaded.
+	first := block
+	for _, served := range chain {
+		if served.NrConfigTx > 0 {
+			first = served
+			break
+		}
+	}
+
+	client := syncedClient(t)
+	if synced := client.store.ReadParametersSyncHeight(); synced != first.Height {
+		t.Fatalf("Parameters synced up to height %v, want %v", synced, first.Height)
+	}
+
+	if _, err := client.GetAccountState(validatorAddress); err == nil {
+		t.Fatal("GetAccountState() with block rewards above the synced parameters succeeded")
+	}
+
+	fakeMiner.Handle(p2p.INTERMEDIATE_NODES_REQ, nil)
+
+	//The parameters are synced again before the rewards are computed.
+	if _, err := client.GetAccountState(validatorAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	if synced := client.store.ReadParametersSyncHeight(); synced != block.Height+1 {
+		t.Errorf("Parameters synced up to height %v, want %v", synced, block.Height+1)
+	}
+}
+
+func TestSubmitTx(t *testing.T) {
+	client := syncedClient(t)
+
+	tx := &protocol.FundsTx{Amount: 2, Fee: 1, TxCnt: 2, From: protocol.SerializeHashContent(newAddress()), To: [32]byte{'t'}}
+	txHash := tx.Hash()
+	client.store.WriteTransaction(txHash, tx)
+
+	if _, err := client.SubmitTx(txHash, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	received := fakeMiner.ReceivedTxs()
+	if len(received) == 0 || received[len(received)-1].Hash() != txHash {
+		t.Fatalf("Fake miner did not receive tx %x", txHash[:8])
+	}
+
+	if status := client.store.ReadTxStatus(txHash); status == nil || status.State != cstorage.TX_SUBMITTED || len(status.Submissions) != 1 {
+		t.Fatalf("Status after submitting = %+v, want submitted once", status)
+	}
+
+	//The tx is verified once the synced chain includes it.
+	block := mine(t, tx)
+	if err := client.syncBlockHeaders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.syncTxStatuses()
+
+	if status := client.store.ReadTxStatus(txHash); status.State != cstorage.TX_VERIFIED || status.Height != block.Height || status.BlockHash != block.Hash {
+		t.Errorf("Status after syncing = %v at height %v, want %v at height %v", status.State, status.Height, cstorage.TX_VERIFIED, block.Height)
+	}
+}
+
+func TestSubmitTxRejected(t *testing.T) {
+	client := syncedClient(t)
+
+	fakeMiner.Handle(p2p.FUNDSTX_BRDCST, func(payload []byte) (uint8, []byte) {
+		return p2p.NOT_FOUND, []byte("fee too low")
+	})
+	defer fakeMiner.Handle(p2p.FUNDSTX_BRDCST, nil)
+
+	tx := &protocol.FundsTx{Amount: 2, TxCnt: 3, From: protocol.SerializeHashContent(newAddress()), To: [32]byte{'t'}}
+	txHash := tx.Hash()
+
+	if _, err := client.SubmitTx(txHash, tx); err == nil {
+		t.Fatal("SubmitTx() of a rejected tx succeeded")
+	}
+
+	status := client.store.ReadTxStatus(txHash)
+	if status == nil || status.State != cstorage.TX_FAILED {
+		t.Fatalf("Status = %+v, want failed", status)
+	}
+
+	if submission := status.Submissions[0]; submission.Accepted || submission.Response != "rejected: fee too low" {
+		t.Errorf("Submission = %+v, want rejected with the miner's reason", submission)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/hex"
+	"github.com/way365/bazo-client/cstorage"
+	"time"
+)
+
+type TxStatusJson struct {
+	TxHash      string                    `json:"txHash"`
+	State       string                    `json:"state"`
+	Updated     time.Time                 `json:"updated"`
+	Submissions []*TxStatusSubmissionJson `json:"submissions,omitempty"`
+	BlockHash   string                    `json:"blockHash,omitempty"`
+	Height      uint32                    `json:"height,omitempty"`
+	Error       string                    `json:"error,omitempty"`
+}
+
+type TxStatusSubmissionJson struct {
+	Peer     string    `json:"peer"`
+	Time     time.Time `json:"time"`
+	Accepted bool      `json:"accepted"`
+	Response string    `json:"response"`
+}
+
+func ConvertTxStatus(status *cstorage.TxStatus) (txStatusJson *TxStatusJson) {
+	txStatusJson = &TxStatusJson{
+		TxHash:  hex.EncodeToString(status.TxHash[:]),
+		State:   status.State.String(),
+		Updated: status.Updated,
+		Error:   status.Error,
+	}
+
+	for _, submission := range status.Submissions {
+		txStatusJson.Submissions = append(txStatusJson.Submissions, &TxStatusSubmissionJson{
+			Peer:     submission.Peer,
+			Time:     submission.Time,
+			Accepted: submission.Accepted,
+			Response: submission.Response,
+		})
+	}
+
+	if status.State == cstorage.TX_INCLUDED || status.State == cstorage.TX_VERIFIED {
+		txStatusJson.BlockHash = hex.EncodeToString(status.BlockHash[:])
+		txStatusJson.Height = status.Height
+	}
+
+	return txStatusJson
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-client/network"
+	"github.com/way365/bazo-miner/protocol"
+	"log"
+	"time"
+)
+
+func init() {
+	onReorg((*Client).rollBackTxStatuses)
+}
+
+// Tx included from the first rolled back height on are no longer part of the synced chain.
+func (client *Client) rollBackTxStatuses(reorg *cstorage.Reorg) {
+	client.txStatusLock.Lock()
+	defer client.txStatusLock.Unlock()
+
+	client.store.RollBackTxStatusesFrom(reorg.Height)
+}
+
+// Background service testing new headers against the submitted tx. Started by Sync.
+func (client *Client) txStatusService(ctx context.Context) {
+	defer client.running.Done()
+
+	for {
+		select {
+		case <-client.txStatusTrigger:
+			client.syncTxStatuses()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (client *Client) triggerTxStatuses() {
+	select {
+	case client.txStatusTrigger <- true:
+	default:
+	}
+}
+
+// Reads the tx's lifecycle record, or starts a new one, and writes it back after update changed it.
+func (client *Client) updateTxStatus(txHash [32]byte, update func(status *cstorage.TxStatus)) {
+	client.txStatusLock.Lock()
+	defer client.txStatusLock.Unlock()
+
+	status := client.store.ReadTxStatus(txHash)
+	if status == nil {
+		status = &cstorage.TxStatus{TxHash: txHash}
+	}
+
+	update(status)
+	status.Updated = time.Now()
+
+	if err := client.store.WriteTxStatus(status); err != nil {
+		logger.Printf("Writing the status of tx %x failed: %v\n", txHash[:8], err)
+	}
+}
+
+func (client *Client) txPrepared(txHash [32]byte) {
+	client.updateTxStatus(txHash, func(status *cstorage.TxStatus) {
+		status.State = cstorage.TX_PREPARED
+	})
+}
+
+// Records that the tx was signed. Called by the REST interface for tx signed by the caller.
+func (client *Client) TxSigned(txHash [32]byte) {
+	client.updateTxStatus(txHash, func(status *cstorage.TxStatus) {
+		if status.State < cstorage.TX_SIGNED {
+			status.State = cstorage.TX_SIGNED
+		}
+	})
+}
+
+// Records the submission to every miner. A tx that no miner accepted failed, unless an earlier submission was
+// accepted.
+func (client *Client) txSubmitted(txHash [32]byte, result *network.BroadcastResult) {
+	client.updateTxStatus(txHash, func(status *cstorage.TxStatus) {
+		for _, submission := range result.Submissions {
+			response := "accepted"
+			if !submission.Accepted {
+				response = submission.Err.Error()
+			}
+
+			status.Submissions = append(status.Submissions, cstorage.TxSubmission{
+				Peer:     submission.Peer,
+				Time:     submission.Time,
+				Accepted: submission.Accepted,
+				Response: response,
+			})
+		}
+
+		if err := result.Err(); err != nil {
+			if status.State < cstorage.TX_SUBMITTED {
+				status.State = cstorage.TX_FAILED
+				status.Error = err.Error()
+			}
+
+			return
+		}
+
+		if status.State < cstorage.TX_SUBMITTED || status.State == cstorage.TX_FAILED {
+			status.State = cstorage.TX_SUBMITTED
+			status.Error = ""
+		}
+	})
+}
+
+// Tests every header up to the last header against the submitted tx and records the blocks they are included in.
+// Included tx are then verified against the Merkle root of the synced header. Processing stops at the first block
+// that cannot be fetched, the next call continues from there.
+func (client *Client) syncTxStatuses() {
+	tip := client.lastHeader()
+	if tip == nil {
+		return
+	}
+
+	//Tx whose inclusion could not be verified yet are verified again.
+	submitted := make(map[[32]byte]protocol.Transaction)
+	for _, status := range client.store.ReadTxStatuses() {
+		tx := client.store.ReadTransaction(status.TxHash)
+		if tx == nil {
+			continue
+		}
+
+		switch status.State {
+		case cstorage.TX_SUBMITTED:
+			submitted[status.TxHash] = tx
+		case cstorage.TX_INCLUDED:
+			if blockHeader := client.store.ReadBlockHeader(status.BlockHash); blockHeader != nil {
+				client.verifyIncludedTx(blockHeader, status.TxHash, tx)
+			}
+		}
+	}
+
+	//Tx are tracked from the height they are submitted at.
+	if len(submitted) == 0 {
+		client.store.WriteTxStatusSyncHeight(tip.Height + 1)
+		return
+	}
+
+	for from := client.store.ReadTxStatusSyncHeight(); from <= tip.Height; from += HEADER_BATCH_SIZE {
+		to := from + HEADER_BATCH_SIZE - 1
+		if to > tip.Height {
+			to = tip.Height
+		}
+
+		for _, blockHeader := range client.store.ReadBlockHeadersByRange(from, to) {
+			matched := false
+			for _, tx := range submitted {
+				if mayContainTx(blockHeader, tx) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				continue
+			}
+
+			if err := client.recordInclusions(blockHeader, submitted); err != nil {
+				logger.Printf("Tracking tx in block %x with height %v failed: %v\n", blockHeader.Hash[:8], blockHeader.Height, err)
+				return
+			}
+
+			client.store.WriteTxStatusSyncHeight(blockHeader.Height + 1)
+		}
+
+		client.store.WriteTxStatusSyncHeight(to + 1)
+	}
+}
+
+// Tests the header's bloom filter against the address hash the miner adds for the tx. ConfigTx and UpdateTx are
+// matched by the number of such tx in the block instead.
+func mayContainTx(blockHeader *protocol.Block, tx protocol.Transaction) bool {
+	var addressHash [32]byte
+	switch tx := tx.(type) {
+	case *protocol.FundsTx:
+		addressHash = tx.From
+	case *protocol.AccTx:
+		addressHash = protocol.SerializeHashContent(tx.PubKey)
+	case *protocol.StakeTx:
+		addressHash = tx.Account
+	case *protocol.ConfigTx:
+		return blockHeader.NrConfigTx > 0
+	case *protocol.UpdateTx:
+		return blockHeader.NrUpdateTx > 0
+	default:
+		return false
+	}
+
+	return blockHeader.NrElementsBF > 0 && blockHeader.BloomFilter.Test(addressHash[:])
+}
+
+// Fetches the block and records the submitted tx it includes. Recorded tx are removed from submitted.
+func (client *Client) recordInclusions(blockHeader *protocol.Block, submitted map[[32]byte]protocol.Transaction) error {
+	blocks, err := getRelevantBlocks([]*protocol.Block{blockHeader})
+	if err != nil {
+		return err
+	}
+
+	block := blocks[0]
+
+	var txHashes [][32]byte
+	for _, data := range [][][32]byte{block.AccTxData, block.FundsTxData, block.ConfigTxData, block.StakeTxData, block.UpdateTxData} {
+		txHashes = append(txHashes, data...)
+	}
+
+	for _, txHash := range txHashes {
+		tx, ok := submitted[txHash]
+		if !ok {
+			continue
+		}
+
+		delete(submitted, txHash)
+
+		client.updateTxStatus(txHash, func(status *cstorage.TxStatus) {
+			status.State = cstorage.TX_INCLUDED
+			status.BlockHash = block.Hash
+			status.Height = block.Height
+		})
+
+		logger.Printf("Tx %x included in block %x with height %v\n", txHash[:8], block.Hash[:8], block.Height)
+
+		client.verifyIncludedTx(block, txHash, tx)
+	}
+
+	return nil
+}
+
+// Verifies the tx's inclusion in the block. A failed verification is recorded and retried on the next sync.
+func (client *Client) verifyIncludedTx(block *protocol.Block, txHash [32]byte, tx protocol.Transaction) {
+	err := client.validateTx(block, tx, txHash)
+
+	verified := false
+	client.updateTxStatus(txHash, func(status *cstorage.TxStatus) {
+		//A reorg may have rolled the inclusion back in the meantime.
+		if status.State != cstorage.TX_INCLUDED || status.BlockHash != block.Hash {
+			return
+		}
+
+		if err != nil {
+			status.Error = err.Error()
+			return
+		}
+
+		status.State = cstorage.TX_VERIFIED
+		status.Error = ""
+		verified = true
+	})
+
+	if err != nil {
+		logger.Println(err)
+	}
+
+	if verified {
+		client.indexVerifiedTx(block, txHash, tx)
+	}
+}
+
+// Syncs the headers, tests them against the submitted tx and prints the tx's lifecycle record.
+func (client *Client) ShowTxStatus(ctx context.Context, args *args.TxStatusArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	if err := client.syncBlockHeaders(ctx); err != nil {
+		return err
+	}
+
+	client.syncTxStatuses()
+
+	status, err := client.GetTxStatus(args.ResolveHash())
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Tx %v: %v, last updated %v\n", status.TxHash, status.State, status.Updated)
+
+	for _, submission := range status.Submissions {
+		logger.Printf("Submitted to %v at %v: %v\n", submission.Peer, submission.Time, submission.Response)
+	}
+
+	if status.BlockHash != "" {
+		logger.Printf("Included in block %v with height %v\n", status.BlockHash, status.Height)
+	}
+
+	if status.Error != "" {
+		logger.Printf("Error: %v\n", status.Error)
+	}
+
+	return nil
+}
+
+func (client *Client) GetTxStatus(txHash [32]byte) (*TxStatusJson, error) {
+	status := client.store.ReadTxStatus(txHash)
+	if status == nil {
+		return nil, errors.New(fmt.Sprintf("No status recorded for tx %x", txHash))
+	}
+
+	return ConvertTxStatus(status), nil
+}
@@ -4,15 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/way365/bazo-client/args"
-	"github.com/way365/bazo-client/cstorage"
 	"github.com/way365/bazo-miner/crypto"
 	"github.com/way365/bazo-miner/protocol"
 	"log"
 	"math/big"
 )
 
-func PrepareSignSubmitUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [32]byte, err error) {
-	txHash, tx, err := PrepareUpdateTx(arguments, logger)
+func (client *Client) PrepareSignSubmitUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [32]byte, err error) {
+	txHash, tx, err := client.PrepareUpdateTx(arguments, logger)
 	if err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
@@ -23,22 +22,22 @@ func PrepareSignSubmitUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger)
 		return [32]byte{}, err
 	}
 
-	if err := SignTx(txHash, tx, issuerPrivateKey); err != nil {
+	if err := client.SignTx(txHash, tx, issuerPrivateKey); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	if err := SubmitTx(txHash, tx); err != nil {
+	if _, err := client.SubmitTx(txHash, tx); err != nil {
 		logger.Printf("%v\n", err)
 		return [32]byte{}, err
 	}
 
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
 
 	return txHash, nil
 }
 
-func PrepareUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.UpdateTx, err error) {
+func (client *Client) PrepareUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [32]byte, tx *protocol.UpdateTx, err error) {
 	err = arguments.ValidateInput()
 	if err != nil {
 		return [32]byte{}, tx, err
@@ -68,7 +67,7 @@ func PrepareUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [
 
 	newData := []byte(arguments.UpdateData)
 	// We create a new check string for TxToDelete to create a hash collision using chameleon hashing.
-	newCheckString := generateCollisionCheckString(txToUpdateHash, parameters, newData)
+	newCheckString := client.generateCollisionCheckString(txToUpdateHash, parameters, newData)
 
 	// Finally, we create the update-tx.
 	tx, err = protocol.ConstrUpdateTx(
@@ -87,19 +86,20 @@ func PrepareUpdateTx(arguments *args.UpdateTxArgs, logger *log.Logger) (txHash [
 	}
 
 	txHash = tx.ChameleonHash(parameters)
-	cstorage.WriteTransaction(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
+	client.txPrepared(txHash)
 
 	return txHash, tx, err
 }
 
-func generateCollisionCheckString(
+func (client *Client) generateCollisionCheckString(
 	txToUpdateHash [32]byte,
 	parameters *crypto.ChameleonHashParameters,
 	newData []byte,
 ) (newCheckString *crypto.ChameleonHashCheckString) {
 	// First we need to query the Tx to update.
 	var txToUpdate protocol.Transaction
-	txToUpdate = cstorage.ReadTransaction(txToUpdateHash)
+	txToUpdate = client.store.ReadTransaction(txToUpdateHash)
 	if txToUpdate == nil {
 		fmt.Printf("TX not found: %x", txToUpdateHash)
 
@@ -124,7 +124,7 @@ func generateCollisionCheckString(
 
 	// We update the TxToUpdate record in our local db.
 	txToUpdate.SetCheckString(newCheckString)
-	cstorage.WriteTransaction(txToUpdateHash, txToUpdate)
+	client.store.WriteTransaction(txToUpdateHash, txToUpdate)
 
 	return newCheckString
 }
@@ -3,6 +3,8 @@ package services
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"github.com/way365/bazo-client/network"
 	"github.com/way365/bazo-client/util"
 	"github.com/way365/bazo-miner/p2p"
@@ -26,7 +28,7 @@ func put(slice []*FundsTxJson, tx *FundsTxJson) {
 	slice[9] = tx
 }
 
-func SignTx(txHash [32]byte, tx protocol.Transaction, privKey *ecdsa.PrivateKey) error {
+func (client *Client) SignTx(txHash [32]byte, tx protocol.Transaction, privKey *ecdsa.PrivateKey) error {
 	var signature [64]byte
 	r, s, err := ecdsa.Sign(rand.Reader, privKey, txHash[:])
 	if err != nil {
@@ -36,11 +38,13 @@ func SignTx(txHash [32]byte, tx protocol.Transaction, privKey *ecdsa.PrivateKey)
 	copy(signature[:32], r.Bytes())
 	copy(signature[32:], s.Bytes())
 	tx.SetSignature(signature)
+	client.TxSigned(txHash)
 
 	return nil
 }
 
-func SubmitTx(txHash [32]byte, tx protocol.Transaction) error {
+// Submits the tx to several miners. Fails if none of them accepted it, the result lists the outcome per miner.
+func (client *Client) SubmitTx(txHash [32]byte, tx protocol.Transaction) (result *network.BroadcastResult, err error) {
 	var typeId uint8
 
 	switch tx.(type) {
@@ -60,12 +64,32 @@ func SubmitTx(txHash [32]byte, tx protocol.Transaction) error {
 		typeId = p2p.NOT_FOUND
 	}
 
-	if err := network.SendTx(util.Config.BootstrapIpport, tx, typeId); err != nil {
+	result = network.BroadcastTx(tx, typeId)
+	logger.Printf("%v\n", result)
+	client.txSubmitted(txHash, result)
+
+	if err := result.Err(); err != nil {
 		logger.Printf("%v\n", err)
-		return err
+		return result, err
 	}
 
 	logger.Printf("Transaction successfully sent to network:\nTxHash: %x%v", txHash, tx.String())
 
-	return nil
+	return result, nil
+}
+
+// Adds the signature to a stored tx and submits it.
+func (client *Client) SubmitSignedTx(txHash [32]byte, signature [64]byte) (result *network.BroadcastResult, err error) {
+	tx := client.store.ReadTransaction(txHash)
+	if tx == nil {
+		return nil, errors.New(fmt.Sprintf("Tx %x not found", txHash[:8]))
+	}
+
+	tx.SetSignature(signature)
+	client.TxSigned(txHash)
+
+	result, err = client.SubmitTx(txHash, tx)
+	client.store.WriteTransaction(txHash, tx)
+
+	return result, err
 }
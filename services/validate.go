@@ -1,49 +1,273 @@
 package services
 
 import (
+	"bytes"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"github.com/way365/bazo-client/network"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/crypto"
 	"github.com/way365/bazo-miner/protocol"
+	"golang.org/x/crypto/sha3"
+	"sync"
+	"time"
 )
 
-//TODO Validate block merkle root.
+var (
+	ErrTxHash      = errors.New("tx does not hash to the requested hash")
+	ErrMerkleProof = errors.New("intermediate nodes do not form a Merkle path")
+	ErrMerkleRoot  = errors.New("Merkle path does not end in the root of the synced block")
+)
+
+// Validates the tx's inclusion in the block end to end: the intermediate nodes returned by a peer must form a path
+// from the tx hash to the Merkle root of the block. Headers carry no Merkle root, the root is taken from the full block,
+// which must hash to the synced header's hash at its height. A block that is not part of the synced chain, such as a
+// block of a branch, is not trusted.
+func (client *Client) validateTx(block *protocol.Block, tx protocol.Transaction, txHash [32]byte) error {
+	if client.store.ReadBlockHashByHeight(block.Height) != block.Hash {
+		return errors.New(fmt.Sprintf("Tx validation failed for %x: header %x is not synced", txHash[:8], block.Hash[:8]))
+	}
+
+	//Stored headers are passed as well, their block is fetched then.
+	if block.HashBlock() != block.Hash {
+		blocks, err := getRelevantBlocks([]*protocol.Block{block})
+		if err != nil {
+			return errors.New(fmt.Sprintf("Tx validation failed for %x: %v", txHash[:8], err))
+		}
+
+		block = blocks[0]
+	}
 
-func validateTx(block *protocol.Block, tx protocol.Transaction, txHash [32]byte) error {
-	valid := true
+	if txHash != tx.Hash() {
+		return fmt.Errorf("Tx validation failed for %x: %w", txHash[:8], ErrTxHash)
+	}
 
-	err := network.IntermediateNodesReq(block.Hash, txHash)
+	future, err := network.IntermediateNodesReq(block.Hash, txHash)
 	if err != nil {
-		//TODO
-		valid = false
+		return errors.New(fmt.Sprintf("Tx validation failed for %x: %v", txHash[:8], err))
 	}
 
-	nodes, err := network.Fetch32Bytes(network.IntermediateNodesChan)
+	nodesI, err := future.Get()
 	if err != nil {
-		//TODO
-		valid = false
+		return errors.New(fmt.Sprintf("Tx validation failed for %x: %v", txHash[:8], err))
 	}
 
-	if txHash != tx.Hash() {
-		valid = false
+	nodes := nodesI.([][32]byte)
+
+	//The nodes are pairs of the sibling and the resulting parent on the path from the leaf to the root.
+	if len(nodes)%2 != 0 {
+		return fmt.Errorf("Tx validation failed for %x: %w", txHash[:8], ErrMerkleProof)
 	}
 
 	leafHash := txHash
 	for i := 0; i < len(nodes); i += 2 {
 		var parentHash [32]byte
 		concatHash := append(leafHash[:], nodes[i][:]...)
-		if parentHash = protocol.SerializeHashContent(concatHash); parentHash != nodes[i+1] {
+		if parentHash = sha3.Sum256(concatHash); parentHash != nodes[i+1] {
 			concatHash = append(nodes[i][:], leafHash[:]...)
-			if parentHash = protocol.SerializeHashContent(concatHash); parentHash != nodes[i+1] {
-				valid = false
+			if parentHash = sha3.Sum256(concatHash); parentHash != nodes[i+1] {
+				return fmt.Errorf("Tx validation failed for %x: %w", txHash[:8], ErrMerkleProof)
 			}
 		}
 		leafHash = parentHash
 	}
 
+	if leafHash != block.MerkleRoot {
+		return fmt.Errorf("Tx validation failed for %x: %w", txHash[:8], ErrMerkleRoot)
+	}
+
+	return nil
+}
+
+var (
+	ErrHeaderHash      = errors.New("header does not match the block it hashes to")
+	ErrHeaderHeight    = errors.New("height does not follow the previous header")
+	ErrHeaderTimestamp = errors.New("timestamp is out of range")
+	ErrHeaderProof     = errors.New("commitment proof does not verify with the beneficiary's commitment key")
+
+	//Not reasons to reject a header, it is validated again once the block and the key can be fetched.
+	ErrHeaderBlock   = errors.New("block of the header could not be fetched")
+	ErrCommitmentKey = errors.New("commitment key of the beneficiary could not be fetched")
+
+	//Commitment keys of beneficiaries, so that the account is not fetched for every header. Headers are validated
+	//by the header sync and one-shot commands concurrently.
+	commitmentKeys     = make(map[[32]byte]*rsa.PublicKey)
+	commitmentKeysLock sync.Mutex
+)
+
+// Returned if a header fails validation. Reason is one of the ErrHeader errors.
+type InvalidHeaderError struct {
+	Hash   [32]byte
+	Height uint32
+	Peer   string
+	Reason error
+}
+
+func (err *InvalidHeaderError) Error() string {
+	return fmt.Sprintf("Header %x with height %v from %v rejected: %v", err.Hash[:8], err.Height, err.Peer, err.Reason)
+}
+
+func (err *InvalidHeaderError) Unwrap() error {
+	return err.Reason
+}
+
+// Validates a header received from peer before it is saved. If prev is not nil, the header must follow it. Miners
+// send headers without the timestamp, the Merkle root and the commitment proof, hence the hash cannot be recomputed
+// from the header. The full block is fetched instead: it must hash to the header's hash and carry the header's
+// fields, its timestamp and commitment proof are validated as the miners do.
+func validateBlockHeader(header *protocol.Block, prev *protocol.Block, peer string) error {
+	invalid := func(reason error) error {
+		return &InvalidHeaderError{header.Hash, header.Height, peer, reason}
+	}
+
+	if header.PrevHash == [32]byte{} && header.Height != 0 {
+		return invalid(ErrHeaderHeight)
+	}
+
+	if prev != nil {
+		if err := validateBlockHeaderLink(header, prev, peer); err != nil {
+			return err
+		}
+	}
+
+	block, err := fetchHeaderBlock(header)
+	if err != nil {
+		return fmt.Errorf("Header %x with height %v not validated: %w", header.Hash[:8], header.Height, err)
+	}
+
+	if !bytes.Equal(block.EncodeHeader(), header.EncodeHeader()) {
+		return invalid(ErrHeaderHash)
+	}
+
+	if block.Timestamp > time.Now().Unix()+util.ACCEPTED_TIME_DIFF {
+		return invalid(ErrHeaderTimestamp)
+	}
+
+	//The genesis block is not proposed by a validator.
+	if block.Height == 0 {
+		return nil
+	}
+
+	valid, err := verifyCommitmentProof(block)
+	if err != nil {
+		return fmt.Errorf("Header %x with height %v not validated: %w", header.Hash[:8], header.Height, err)
+	}
+
 	if !valid {
-		return errors.New(fmt.Sprintf("Tx validation failed for %x\n", txHash))
+		return invalid(ErrHeaderProof)
+	}
+
+	return nil
+}
+
+// Fetches the block of the header. A block that does not hash to the header's hash was forged by the peer that sent
+// it, the peer is flagged. Returns an error wrapping ErrHeaderBlock if no valid block was received.
+func fetchHeaderBlock(header *protocol.Block) (*protocol.Block, error) {
+	future, err := network.BlockReq(header.Hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHeaderBlock, err)
+	}
+
+	blockI, err := future.Get()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHeaderBlock, err)
+	}
+
+	block := blockI.(*protocol.Block)
+	if block == nil {
+		return nil, fmt.Errorf("%w: block %x could not be decoded", ErrHeaderBlock, header.Hash[:8])
+	}
+
+	if block.HashBlock() != header.Hash {
+		reason := errors.New(fmt.Sprintf("block %x does not hash to its hash", header.Hash[:8]))
+		network.FlagPeer(future.Peer(), reason)
+
+		return nil, fmt.Errorf("%w: %v", ErrHeaderBlock, reason)
+	}
+
+	return block, nil
+}
+
+// Validates that header directly follows prev. The error blames peer.
+func validateBlockHeaderLink(header *protocol.Block, prev *protocol.Block, peer string) error {
+	if header.PrevHash != prev.Hash || header.Height != prev.Height+1 {
+		return &InvalidHeaderError{prev.Hash, prev.Height, peer, ErrHeaderHeight}
 	}
 
 	return nil
 }
+
+// Verifies the proof the beneficiary committed to the block's height with, as the miners do before accepting a
+// block. Returns an error wrapping ErrCommitmentKey if the beneficiary's commitment key cannot be fetched.
+func verifyCommitmentProof(block *protocol.Block) (valid bool, err error) {
+	commitmentKeysLock.Lock()
+	commitmentKey, cached := commitmentKeys[block.Beneficiary]
+	commitmentKeysLock.Unlock()
+
+	if !cached {
+		if commitmentKey, err = fetchCommitmentKey(block.Beneficiary); err != nil {
+			return false, err
+		}
+	}
+
+	if commitmentKey != nil && crypto.VerifyMessageWithRSAKey(commitmentKey, fmt.Sprint(block.Height), block.CommitmentProof) == nil {
+		commitmentKeysLock.Lock()
+		commitmentKeys[block.Beneficiary] = commitmentKey
+		commitmentKeysLock.Unlock()
+
+		return true, nil
+	}
+
+	//The validator might have changed its commitment key since it was cached.
+	if cached {
+		commitmentKeysLock.Lock()
+		delete(commitmentKeys, block.Beneficiary)
+		commitmentKeysLock.Unlock()
+
+		return verifyCommitmentProof(block)
+	}
+
+	return false, nil
+}
+
+// Fetches the beneficiary's commitment key from the miners, they have to agree on the account. Returns nil if the
+// agreed account is not staking or carries no valid key, the proof cannot verify then.
+func fetchCommitmentKey(beneficiary [32]byte) (*rsa.PublicKey, error) {
+	future, err := network.UnanimousAccReq(beneficiary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCommitmentKey, err)
+	}
+
+	accI, err := future.Get()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCommitmentKey, err)
+	}
+
+	acc := accI.(*protocol.Account)
+	if acc == nil {
+		return nil, fmt.Errorf("%w: account %x could not be decoded", ErrCommitmentKey, beneficiary[:8])
+	}
+
+	//Only staking accounts propose blocks.
+	if !acc.IsStaking {
+		return nil, nil
+	}
+
+	commitmentKey, err := crypto.CreateRSAPubKeyFromBytes(acc.CommitmentKey)
+	if err != nil {
+		return nil, nil
+	}
+
+	return commitmentKey, nil
+}
+
+// Logs a rejected header and flags the peer it was received from if the header is invalid.
+func rejectBlockHeader(err error) {
+	logger.Println(err)
+
+	var invalid *InvalidHeaderError
+	if errors.As(err, &invalid) && invalid.Peer != "" {
+		network.FlagPeer(invalid.Peer, invalid)
+	}
+}
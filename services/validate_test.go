@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-client/util"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSyncRetriesUnfetchableCommitmentKey(t *testing.T) {
+	mine(t)
+
+	commitmentKeysLock.Lock()
+	commitmentKeys = make(map[[32]byte]*rsa.PublicKey)
+	commitmentKeysLock.Unlock()
+
+	//The first account request is not answered and times out.
+	var requests int32
+	fakeMiner.Handle(p2p.ACC_REQ, func(payload []byte) (uint8, []byte) {
+		atomic.AddInt32(&requests, 1)
+		return 0, nil
+	})
+	defer fakeMiner.Handle(p2p.ACC_REQ, nil)
+
+	client := NewClient(cstorage.NewMemoryStore())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*util.FETCH_TIMEOUT*time.Second)
+	defer cancel()
+
+	synced := make(chan error, 1)
+	go func() {
+		synced <- client.syncBlockHeaders(ctx)
+	}()
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&requests) > 0 })
+	fakeMiner.Handle(p2p.ACC_REQ, nil)
+
+	if err := <-synced; err != nil {
+		t.Fatal(err)
+	}
+
+	assertSynced(t, client)
+}
+
+func TestValidateBlockHeader(t *testing.T) {
+	block := mine(t)
+
+	//The header as the miner sends it.
+	header := new(protocol.Block).Decode(block.EncodeHeader())
+	if err := validateBlockHeader(header, chain[len(chain)-2], ""); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := new(protocol.Block).Decode(block.EncodeHeader())
+	forged.NrConfigTx = 1
+	if err := validateBlockHeader(forged, nil, ""); !errors.Is(err, ErrHeaderHash) {
+		t.Errorf("validateBlockHeader() of a forged header = %v, want %v", err, ErrHeaderHash)
+	}
+}
+
+func TestValidateTxRequiresSyncedChain(t *testing.T) {
+	client := syncedClient(t)
+
+	//A stored header of a competing branch does not provide the Merkle root.
+	tx := &protocol.FundsTx{Amount: 7, TxCnt: 7, From: [32]byte{'s'}, To: protocol.SerializeHashContent(newAddress())}
+	branch := newBlock(t, chain[len(chain)-2], tx)
+	client.store.WriteBranchBlockHeader(branch)
+
+	if err := client.validateTx(branch, tx, tx.Hash()); err == nil {
+		t.Error("Tx of a branch header validated")
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-client/cstorage"
+	"github.com/way365/bazo-miner/p2p"
+	"github.com/way365/bazo-miner/protocol"
+	"log"
+)
+
+func init() {
+	onReorg((*Client).dropActivities)
+}
+
+// Activity from the first rolled back height on was recorded from rolled back blocks.
+func (client *Client) dropActivities(reorg *cstorage.Reorg) {
+	client.watchLock.Lock()
+	defer client.watchLock.Unlock()
+
+	client.store.DeleteActivitiesFrom(reorg.Height)
+}
+
+// Background service testing new headers against the watch list. Started by Sync.
+func (client *Client) watchService(ctx context.Context) {
+	defer client.running.Done()
+
+	for {
+		select {
+		case <-client.watchTrigger:
+			client.syncWatchList()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (client *Client) triggerWatchList() {
+	select {
+	case client.watchTrigger <- true:
+	default:
+	}
+}
+
+// Tests every header up to the last header against the watched addresses' hashes and records the verified
+// transactions of matching blocks. Processing stops at the first block that cannot be fetched or verified, the
+// next call continues from there.
+func (client *Client) syncWatchList() {
+	client.watchLock.Lock()
+	defer client.watchLock.Unlock()
+
+	tip := client.lastHeader()
+	if tip == nil {
+		return
+	}
+	addresses := client.store.ReadWatchedAddresses()
+
+	//Nothing to test, addresses added later are tracked from the height they are added at.
+	if len(addresses) == 0 {
+		client.store.WriteWatchSyncHeight(tip.Height + 1)
+		return
+	}
+
+	addressHashes := make([][32]byte, len(addresses))
+	startHeights := make([]uint32, len(addresses))
+	for i, address := range addresses {
+		addressHashes[i] = protocol.SerializeHashContent(address)
+		startHeights[i] = client.store.ReadWatchStartHeight(address)
+	}
+
+	for from := client.store.ReadWatchSyncHeight(); from <= tip.Height; from += HEADER_BATCH_SIZE {
+		to := from + HEADER_BATCH_SIZE - 1
+		if to > tip.Height {
+			to = tip.Height
+		}
+
+		for _, blockHeader := range client.store.ReadBlockHeadersByRange(from, to) {
+			if blockHeader.NrElementsBF == 0 {
+				continue
+			}
+
+			var matched []int
+			for i, addressHash := range addressHashes {
+				if blockHeader.Height >= startHeights[i] && blockHeader.BloomFilter.Test(addressHash[:]) {
+					matched = append(matched, i)
+				}
+			}
+
+			if len(matched) == 0 {
+				continue
+			}
+
+			if err := client.recordActivities(blockHeader, addresses, addressHashes, matched); err != nil {
+				logger.Printf("Tracking block %x with height %v failed: %v\n", blockHeader.Hash[:8], blockHeader.Height, err)
+				return
+			}
+
+			client.store.WriteWatchSyncHeight(blockHeader.Height + 1)
+		}
+
+		client.store.WriteWatchSyncHeight(to + 1)
+	}
+}
+
+// Fetches the block, verifies the transactions of the matched addresses and records them as activity.
+func (client *Client) recordActivities(blockHeader *protocol.Block, addresses [][64]byte, addressHashes [][32]byte, matched []int) error {
+	blocks, err := getRelevantBlocks([]*protocol.Block{blockHeader})
+	if err != nil {
+		return err
+	}
+
+	block := blocks[0]
+
+	for _, txHash := range block.FundsTxData {
+		txI, err := fetchTx(p2p.FUNDSTX_REQ, txHash)
+		if err != nil {
+			return err
+		}
+
+		fundsTx := txI.(*protocol.FundsTx)
+		activity := &cstorage.Activity{
+			Height:    block.Height,
+			BlockHash: block.Hash,
+			TxHash:    txHash,
+			TxType:    "funds",
+			From:      fundsTx.From,
+			To:        fundsTx.To,
+			Amount:    fundsTx.Amount,
+			Fee:       fundsTx.Fee,
+		}
+
+		if err := client.recordActivity(block, fundsTx, activity, addresses, matched, func(i int) bool {
+			return fundsTx.From == addressHashes[i] || fundsTx.To == addressHashes[i]
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, txHash := range block.AccTxData {
+		txI, err := fetchTx(p2p.ACCTX_REQ, txHash)
+		if err != nil {
+			return err
+		}
+
+		accTx := txI.(*protocol.AccTx)
+		activity := &cstorage.Activity{
+			Height:    block.Height,
+			BlockHash: block.Hash,
+			TxHash:    txHash,
+			TxType:    "acc",
+			From:      accTx.Issuer,
+			To:        protocol.SerializeHashContent(accTx.PubKey),
+			Fee:       accTx.Fee,
+		}
+
+		if err := client.recordActivity(block, accTx, activity, addresses, matched, func(i int) bool {
+			return accTx.PubKey == addresses[i]
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, txHash := range block.StakeTxData {
+		txI, err := fetchTx(p2p.STAKETX_REQ, txHash)
+		if err != nil {
+			return err
+		}
+
+		stakeTx := txI.(*protocol.StakeTx)
+		activity := &cstorage.Activity{
+			Height:    block.Height,
+			BlockHash: block.Hash,
+			TxHash:    txHash,
+			TxType:    "stake",
+			From:      stakeTx.Account,
+			Fee:       stakeTx.Fee,
+			IsStaking: stakeTx.IsStaking,
+		}
+
+		if err := client.recordActivity(block, stakeTx, activity, addresses, matched, func(i int) bool {
+			return stakeTx.Account == addressHashes[i]
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verifies the tx once if it concerns any matched address and records the activity for each of them.
+func (client *Client) recordActivity(block *protocol.Block, tx protocol.Transaction, activity *cstorage.Activity, addresses [][64]byte, matched []int, concerns func(i int) bool) error {
+	verified := false
+	for _, i := range matched {
+		if !concerns(i) {
+			continue
+		}
+
+		if !verified {
+			if err := client.validateTx(block, tx, activity.TxHash); err != nil {
+				return err
+			}
+
+			client.indexVerifiedTx(block, activity.TxHash, tx)
+			verified = true
+		}
+
+		client.store.WriteActivity(addresses[i], activity)
+		logger.Printf("Recorded %v tx %x with height %v for watched address %x\n", activity.TxType, activity.TxHash[:8], activity.Height, addresses[i][:8])
+	}
+
+	return nil
+}
+
+func (client *Client) AddWatchedAddress(args *args.WatchArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	//Activity is recorded from the first height above the synced chain on.
+	var height uint32
+	if last := client.store.ReadLastBlockHeader(); last != nil {
+		height = last.Height + 1
+	}
+
+	client.watchLock.Lock()
+	defer client.watchLock.Unlock()
+
+	if err := client.store.WriteWatchedAddress(args.ResolveAddress(), height); err != nil {
+		return err
+	}
+
+	//Heights above the last tested one must not be skipped for the new address.
+	if client.store.ReadWatchSyncHeight() > height {
+		if err := client.store.WriteWatchSyncHeight(height); err != nil {
+			return err
+		}
+	}
+
+	logger.Printf("Watching %v\n", args.Address)
+
+	return nil
+}
+
+func (client *Client) RemoveWatchedAddress(args *args.WatchArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	client.watchLock.Lock()
+	client.store.DeleteWatchedAddress(args.ResolveAddress())
+	client.watchLock.Unlock()
+
+	logger.Printf("Stopped watching %v\n", args.Address)
+
+	return nil
+}
+
+func (client *Client) ListWatchedAddresses(logger *log.Logger) error {
+	for _, address := range client.GetWatchedAddresses() {
+		logger.Println(address)
+	}
+
+	return nil
+}
+
+// Syncs the headers, tests them against the watch list and prints the address' recorded activity.
+func (client *Client) ShowWatchActivity(ctx context.Context, args *args.WatchArgs, logger *log.Logger) error {
+	err := args.ValidateInput()
+	if err != nil {
+		return err
+	}
+
+	if err := client.syncBlockHeaders(ctx); err != nil {
+		return err
+	}
+
+	client.syncWatchList()
+
+	for _, activity := range client.GetWatchActivity(args.ResolveAddress()) {
+		logger.Printf("Height %v: %v tx %v, from %v, to %v, amount %v, fee %v\n",
+			activity.Height,
+			activity.TxType,
+			activity.TxHash,
+			activity.From,
+			activity.To,
+			activity.Amount,
+			activity.Fee)
+	}
+
+	return nil
+}
+
+func (client *Client) GetWatchedAddresses() (addresses []string) {
+	for _, address := range client.store.ReadWatchedAddresses() {
+		addresses = append(addresses, hex.EncodeToString(address[:]))
+	}
+
+	return addresses
+}
+
+func (client *Client) GetWatchActivity(address [64]byte) (activities []*ActivityJson) {
+	for _, activity := range client.store.ReadActivities(address) {
+		activities = append(activities, ConvertActivity(activity))
+	}
+
+	return activities
+}
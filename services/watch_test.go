@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/way365/bazo-client/args"
+	"github.com/way365/bazo-miner/protocol"
+	"testing"
+)
+
+func TestWatchListStartsAtAddedHeight(t *testing.T) {
+	watched := newAddress()
+	before := &protocol.FundsTx{Amount: 3, TxCnt: 4, From: [32]byte{'s'}, To: protocol.SerializeHashContent(watched)}
+	mine(t, before)
+
+	client := syncedClient(t)
+	if err := client.AddWatchedAddress(&args.WatchArgs{Address: hex.EncodeToString(watched[:])}, logger); err != nil {
+		t.Fatal(err)
+	}
+
+	after := &protocol.FundsTx{Amount: 4, TxCnt: 5, From: [32]byte{'s'}, To: protocol.SerializeHashContent(watched)}
+	block := mine(t, after)
+
+	if err := client.syncBlockHeaders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.syncWatchList()
+
+	activities := client.store.ReadActivities(watched)
+	if len(activities) != 1 || activities[0].TxHash != after.Hash() || activities[0].Height != block.Height {
+		t.Fatalf("Activities = %v, want only the tx at height %v", activities, block.Height)
+	}
+
+	if got := client.store.ReadWatchSyncHeight(); got != block.Height+1 {
+		t.Errorf("ReadWatchSyncHeight() = %v, want %v", got, block.Height+1)
+	}
+}
@@ -3,15 +3,27 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 )
 
 const (
 	CONFIGURATION_FILE = "configuration.json"
 
-	HEALTH_CHECK_INTERVAL = 30 //Sec
-	MIN_MINERS            = 1
-	FETCH_TIMEOUT         = 10 //SEC
+	HEALTH_CHECK_INTERVAL = 30  //Sec
+	MIN_MINERS            = 1   //Default number of miner connections to keep
+	FETCH_TIMEOUT         = 10  //SEC
+	ACCEPTED_TIME_DIFF    = 60  //Sec, how far a header's timestamp may lie in the future
+	RECONNECT_BACKOFF_MIN = 1   //Sec, doubled after every failed attempt to connect to a miner
+	RECONNECT_BACKOFF_MAX = 300 //Sec
+	FETCH_RETRY_MAX       = 60  //Sec, longest pause between attempts to fetch a header no miner delivered
+	MAX_PEER_FAILURES     = 5   //Consecutive timed out requests after which a miner is disconnected
+	FRAME_READ_TIMEOUT    = 30  //Sec, how long a started message may take to arrive completely
+	BROADCAST_PEERS       = 3   //Default number of miners a tx is submitted to
+	BROADCAST_ATTEMPTS    = 3   //Attempts per miner that cannot be reached or times out
+
+	STORAGE_BACKEND = "bolt"      //Default storage backend, "bolt" or "memory"
+	STORAGE_PATH    = "client.db" //Default DB file of the bolt backend
 )
 
 var (
@@ -34,6 +46,24 @@ type Configuration struct {
 		Ip   string `json:"ip"`
 		Port string `json:"port"`
 	} `json:"multisig_server"`
+	MinerConnections int `json:"miner_connections"`
+	QuorumPeers      int `json:"quorum_peers"`
+	QuorumThreshold  int `json:"quorum_threshold"`
+	BroadcastPeers   int `json:"broadcast_peers"`
+
+	Storage struct {
+		Backend string `json:"backend"`
+		Path    string `json:"path"`
+	} `json:"storage"`
+
+	//bazo-miner has no TLS listener, TLS connections go to a TLS-terminating proxy in front of the miner.
+	TLS struct {
+		Enabled       bool              `json:"enabled"`
+		PinnedKeys    map[string]string `json:"pinned_keys"`
+		AllowUnpinned bool              `json:"allow_unpinned"`
+		Cert          string            `json:"cert"`
+		Key           string            `json:"key"`
+	} `json:"tls"`
 }
 
 func LoadConfiguration() (config Configuration) {
@@ -45,8 +75,46 @@ func LoadConfiguration() (config Configuration) {
 	jsonParser := json.NewDecoder(configFile)
 	jsonParser.Decode(&config)
 
-	config.ThisIpport = config.Thisclient.Ip + ":" + config.Thisclient.Port
-	config.BootstrapIpport = config.Bootstrapserver.Ip + ":" + config.Bootstrapserver.Port
-	config.MultisigIpport = config.Multisigserver.Ip + ":" + config.Multisigserver.Port
+	//JoinHostPort brackets ipv6 addresses, hostnames and ipv4 addresses are kept as they are.
+	config.ThisIpport = net.JoinHostPort(config.Thisclient.Ip, config.Thisclient.Port)
+	config.BootstrapIpport = net.JoinHostPort(config.Bootstrapserver.Ip, config.Bootstrapserver.Port)
+	config.MultisigIpport = net.JoinHostPort(config.Multisigserver.Ip, config.Multisigserver.Port)
+
+	if config.MinerConnections <= 0 {
+		config.MinerConnections = MIN_MINERS
+	}
+
+	//Pins are looked up by the normalized address of the proxy.
+	pinnedKeys := make(map[string]string)
+	for ipport, pin := range config.TLS.PinnedKeys {
+		if host, port, err := net.SplitHostPort(ipport); err == nil {
+			ipport = net.JoinHostPort(host, port)
+		}
+		pinnedKeys[ipport] = pin
+	}
+	config.TLS.PinnedKeys = pinnedKeys
+
+	if config.Storage.Backend == "" {
+		config.Storage.Backend = STORAGE_BACKEND
+	}
+
+	if config.Storage.Path == "" {
+		config.Storage.Path = STORAGE_PATH
+	}
+
+	if config.BroadcastPeers <= 0 {
+		config.BroadcastPeers = BROADCAST_PEERS
+	}
+
+	//Without a threshold, the majority of the quorum has to agree.
+	if config.QuorumThreshold <= 0 {
+		config.QuorumThreshold = config.QuorumPeers/2 + 1
+	}
+
+	//The quorum needs enough connections to reach its peers.
+	if config.QuorumPeers > config.MinerConnections {
+		config.MinerConnections = config.QuorumPeers
+	}
+
 	return config
 }
@@ -8,12 +8,16 @@ import (
 
 func InitLogger() *log.Logger {
 
+	var wrt io.Writer = os.Stdout
+
+	//Log to stdout only if the log file cannot be opened.
 	performanceLogFile, err := os.OpenFile("PerformanceLoggerClient.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		log.Printf("error opening file: %v", err)
+	} else {
+		wrt = io.MultiWriter(os.Stdout, performanceLogFile)
 	}
 
-	wrt := io.MultiWriter(os.Stdout, performanceLogFile)
 	log.SetOutput(wrt)
 	return log.New(wrt, "INFO: ", log.Ldate|log.Lmicroseconds|log.Lshortfile)
 }